@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+)
+
+//The archive is a folder of JSON files, one for every match we've ever looked up, grouped by the account that played it.
+//Finished matches never change, so once a match is written here we never have to ask Riot for it again.
+//It's ./archive unless IVERN_ARCHIVE_DIR says otherwise.
+var archiveDir = "archive"
+
+//regionArchive is where one region's matches, timelines and LP history are kept. Account and game IDs are only unique
+//within a platform, so every other region gets a folder of its own. NA stays at the top, where everything was kept
+//before there were other regions, and its folder walks skip the region folders since they aren't account IDs.
+func regionArchive(region string) string {
+	if region == defaultRegion {
+		return archiveDir
+	}
+	return filepath.Join(archiveDir, region)
+}
+
+//archiveMatch writes a single match to archive/<accountID>/<gameID>.json (under the region's folder outside NA), replacing any older copy of it.
+func archiveMatch(region string, accountID int, m MatchInfo) error {
+
+	dir := filepath.Join(regionArchive(region), strconv.Itoa(accountID))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	if err := writeFileAtomic(matchPath(region, accountID, m.GameID), data); err != nil {
+		return err
+	}
+
+	indexMatch(region, accountID, m)
+	return nil
+}
+
+func matchPath(region string, accountID int, gameID int64) string {
+	return filepath.Join(regionArchive(region), strconv.Itoa(accountID), strconv.FormatInt(gameID, 10)+".json")
+}
+
+//hasMatch reports whether we already stored this match for the account
+func hasMatch(region string, accountID int, gameID int64) bool {
+	_, err := os.Stat(matchPath(region, accountID, gameID))
+	return err == nil
+}
+
+//writeFileAtomic writes to a temporary file first and then moves it into place, so a crash halfway through never
+//leaves a broken file behind, and two searches saving the same file at once can't mix their writes together.
+func writeFileAtomic(path string, data []byte) error {
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+//loadArchive reads every stored match for an account, newest first.
+//An account we've never seen simply has no matches, which isn't an error.
+func loadArchive(region string, accountID int) ([]MatchInfo, error) {
+
+	files, err := filepath.Glob(filepath.Join(regionArchive(region), strconv.Itoa(accountID), "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	matches := make([]MatchInfo, 0, len(files))
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+
+		var m MatchInfo
+		if err := json.Unmarshal(data, &m); err != nil {
+			return nil, err
+		}
+		m.Stats.derive()
+		matches = append(matches, m)
+	}
+
+	sort.Slice(matches, func(a, b int) bool { return matches[a].Timestamp > matches[b].Timestamp })
+
+	return matches, nil
+}
+
+//Account folders are named after the account ID. Everything else in the archive (timelines, lp) isn't matches.
+func isAccountDir(name string) bool {
+	_, err := strconv.Atoi(name)
+	return err == nil
+}
+
+//loadArchivedMatch reads back a single stored match for an account
+func loadArchivedMatch(region string, accountID int, gameID int64) (MatchInfo, error) {
+
+	var m MatchInfo
+
+	data, err := os.ReadFile(matchPath(region, accountID, gameID))
+	if err != nil {
+		return m, err
+	}
+
+	if err := json.Unmarshal(data, &m); err != nil {
+		return m, err
+	}
+	m.Stats.derive()
+
+	return m, nil
+}
+
+//Timelines belong to a match rather than to any one player, so they all live together in archive/timelines/<gameID>.json
+func timelinePath(region string, gameID int64) string {
+	return filepath.Join(regionArchive(region), "timelines", strconv.FormatInt(gameID, 10)+".json")
+}
+
+func hasTimeline(region string, gameID int64) bool {
+	_, err := os.Stat(timelinePath(region, gameID))
+	return err == nil
+}
+
+func archiveTimeline(region string, gameID int64, tl Timeline) error {
+
+	if err := os.MkdirAll(filepath.Dir(timelinePath(region, gameID)), 0755); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(tl)
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(timelinePath(region, gameID), data)
+}
+
+//loadTimeline reads a stored timeline. ok is false when we never managed to fetch one for this match.
+func loadTimeline(region string, gameID int64) (tl Timeline, ok bool, err error) {
+
+	data, err := os.ReadFile(timelinePath(region, gameID))
+	if os.IsNotExist(err) {
+		return tl, false, nil
+	}
+	if err != nil {
+		return tl, false, err
+	}
+
+	if err := json.Unmarshal(data, &tl); err != nil {
+		return tl, false, err
+	}
+
+	return tl, true, nil
+}
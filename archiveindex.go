@@ -0,0 +1,219 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//playerIndexTTL is how long the index trusts itself before looking for matches other processes (like `ivern sync`) archived.
+//Looking only lists folders, and only files it hasn't seen before are read.
+const playerIndexTTL = time.Minute
+
+//playerIndex knows, for one region's archive, which account folders every player shows up in and what each name last
+//belonged to. It means the live game page and stale lookups only read the accounts they need, instead of the whole archive.
+type playerIndex struct {
+	sync.Mutex
+	region string
+
+	//indexed is every match file already read, so a refresh only reads new ones
+	indexed map[string]bool
+
+	//accounts maps a summoner ID to the account folders holding games they played in
+	accounts map[int]map[int]bool
+
+	//names maps a lowercased summoner name to the newest sighting of it
+	names map[string]sighting
+
+	refreshed time.Time
+}
+
+//sighting is a player as they were in one archived match
+type sighting struct {
+	Profile
+	Timestamp int64
+}
+
+//playerIndexes holds one index per region's archive folder
+var playerIndexes = struct {
+	sync.Mutex
+	byDir map[string]*playerIndex
+}{byDir: make(map[string]*playerIndex)}
+
+//indexFor hands back the index for a region, brought up to date if it hasn't been looked at for playerIndexTTL
+func indexFor(region string) (*playerIndex, error) {
+
+	playerIndexes.Lock()
+	x, ok := playerIndexes.byDir[regionArchive(region)]
+	if !ok {
+		x = &playerIndex{
+			region:   region,
+			indexed:  make(map[string]bool),
+			accounts: make(map[int]map[int]bool),
+			names:    make(map[string]sighting),
+		}
+		playerIndexes.byDir[regionArchive(region)] = x
+	}
+	playerIndexes.Unlock()
+
+	x.Lock()
+	defer x.Unlock()
+
+	if !x.refreshed.IsZero() && time.Since(x.refreshed) < playerIndexTTL {
+		return x, nil
+	}
+	if err := x.refresh(); err != nil {
+		return nil, err
+	}
+	x.refreshed = time.Now()
+
+	return x, nil
+}
+
+//refresh reads every match file in the region's archive that isn't indexed yet. The lock must be held.
+func (x *playerIndex) refresh() error {
+
+	dirs, err := os.ReadDir(regionArchive(x.region))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, d := range dirs {
+		if !d.IsDir() || !isAccountDir(d.Name()) {
+			continue
+		}
+		accountID, _ := strconv.Atoi(d.Name())
+
+		files, err := filepath.Glob(filepath.Join(regionArchive(x.region), d.Name(), "*.json"))
+		if err != nil {
+			return err
+		}
+		for _, f := range files {
+			if x.indexed[f] {
+				continue
+			}
+
+			data, err := os.ReadFile(f)
+			if err != nil {
+				return err
+			}
+			var m MatchInfo
+			if err := json.Unmarshal(data, &m); err != nil {
+				return err
+			}
+			x.add(f, accountID, m)
+		}
+	}
+
+	return nil
+}
+
+//add indexes one match stored at path under accountID. The lock must be held.
+func (x *playerIndex) add(path string, accountID int, m MatchInfo) {
+
+	x.indexed[path] = true
+
+	for _, id := range m.Stats.ParticipantIdentities {
+		p := id.Player
+
+		if p.SummonerID != 0 {
+			if x.accounts[p.SummonerID] == nil {
+				x.accounts[p.SummonerID] = make(map[int]bool)
+			}
+			x.accounts[p.SummonerID][accountID] = true
+		}
+
+		//Names change, so the newest match anyone used a name in decides who it is
+		key := strings.ToLower(p.SummonerName)
+		if seen, ok := x.names[key]; !ok || m.Timestamp > seen.Timestamp {
+			x.names[key] = sighting{
+				Profile: Profile{
+					Name:          p.SummonerName,
+					ProfileIconID: p.ProfileIcon,
+					AccountID:     p.AccountID,
+					ID:            p.SummonerID,
+				},
+				Timestamp: m.Timestamp,
+			}
+		}
+	}
+}
+
+//indexMatch adds a match that was just archived to its region's index, if that index has been built.
+//An index that hasn't been built yet will find the file when it is.
+func indexMatch(region string, accountID int, m MatchInfo) {
+
+	playerIndexes.Lock()
+	x, ok := playerIndexes.byDir[regionArchive(region)]
+	playerIndexes.Unlock()
+	if !ok {
+		return
+	}
+
+	x.Lock()
+	x.add(matchPath(region, accountID, m.GameID), accountID, m)
+	x.Unlock()
+}
+
+//accountsWith lists the account folders holding games any of the summoners played in
+func (x *playerIndex) accountsWith(summonerIDs ...int) []int {
+
+	x.Lock()
+	defer x.Unlock()
+
+	found := make(map[int]bool)
+	for _, id := range summonerIDs {
+		for accountID := range x.accounts[id] {
+			found[accountID] = true
+		}
+	}
+
+	return sortedKeys(found)
+}
+
+//player finds who last went by name in the archive
+func (x *playerIndex) player(name string) (Profile, bool) {
+
+	x.Lock()
+	defer x.Unlock()
+
+	seen, ok := x.names[strings.ToLower(name)]
+	return seen.Profile, ok
+}
+
+//loadArchivesWith reads the archived matches any of the summoners played in, with each match only once even if several
+//of the players in it have been searched. Each match still points at whichever account it was stored under.
+func loadArchivesWith(region string, summonerIDs ...int) ([]MatchInfo, error) {
+
+	x, err := indexFor(region)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[int64]bool)
+	var all []MatchInfo
+	for _, accountID := range x.accountsWith(summonerIDs...) {
+		matches, err := loadArchive(region, accountID)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range matches {
+			if !seen[m.GameID] {
+				seen[m.GameID] = true
+				all = append(all, m)
+			}
+		}
+	}
+
+	sort.Slice(all, func(a, b int) bool { return all[a].Timestamp > all[b].Timestamp })
+
+	return all, nil
+}
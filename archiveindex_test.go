@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+//indexedMatch is a game with the given summoner IDs in it, under the given names
+func indexedMatch(gameID int64, timestamp int64, names map[int]string) MatchInfo {
+
+	m := MatchInfo{GameID: gameID, Timestamp: timestamp}
+	for id, name := range names {
+		var p ParticipantIdentity
+		p.Player.SummonerID = id
+		p.Player.AccountID = id * 10
+		p.Player.SummonerName = name
+		m.Stats.ParticipantIdentities = append(m.Stats.ParticipantIdentities, p)
+	}
+	return m
+}
+
+func TestPlayerIndex(t *testing.T) {
+
+	oldDir := archiveDir
+	archiveDir = t.TempDir()
+	t.Cleanup(func() { archiveDir = oldDir })
+
+	//Player 1's games are stored under account 10, player 3's under 30, and player 2 played with both
+	archiveMatch("na", 10, indexedMatch(1, 100, map[int]string{1: "One", 2: "Old Name"}))
+	archiveMatch("na", 30, indexedMatch(2, 200, map[int]string{3: "Three", 2: "Two"}))
+
+	matches, err := loadArchivesWith("na", 1)
+	if err != nil || len(matches) != 1 || matches[0].GameID != 1 {
+		t.Errorf("player 1 got %v, %v, want only game 1", matches, err)
+	}
+	matches, err = loadArchivesWith("na", 2, 3)
+	if err != nil || len(matches) != 2 || matches[0].GameID != 2 {
+		t.Errorf("players 2 and 3 got %v, %v, want games 2 then 1", matches, err)
+	}
+
+	//A match archived while the index is built goes straight in
+	archiveMatch("na", 40, indexedMatch(3, 300, map[int]string{4: "Four"}))
+	if matches, _ := loadArchivesWith("na", 4); len(matches) != 1 {
+		t.Errorf("player 4 got %d games, want 1", len(matches))
+	}
+
+	//Another process writing to the archive shows up once the index is due for a refresh
+	data, _ := json.Marshal(indexedMatch(4, 400, map[int]string{5: "Old Name"}))
+	os.MkdirAll(filepath.Join(archiveDir, "50"), 0755)
+	os.WriteFile(filepath.Join(archiveDir, "50", "4.json"), data, 0644)
+
+	x, _ := indexFor("na")
+	if _, ok := x.player("two"); !ok {
+		t.Error("names should be found whatever their case")
+	}
+	if p, _ := x.player("old name"); p.ID != 2 {
+		t.Errorf("old name belongs to %d before the refresh, want 2", p.ID)
+	}
+
+	x.Lock()
+	x.refreshed = time.Now().Add(-playerIndexTTL)
+	x.Unlock()
+	x, _ = indexFor("na")
+	if p, _ := x.player("old name"); p.ID != 5 {
+		t.Errorf("old name belongs to %d after the refresh, want 5, who used it last", p.ID)
+	}
+
+	//Other regions have their own folder and their own index
+	if matches, _ := loadArchivesWith("euw", 1); len(matches) != 0 {
+		t.Errorf("euw found %d games for an NA player", len(matches))
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+//errRiotUnavailable is what riotGet hands back without even trying while the breaker is open
+var errRiotUnavailable = errors.New("riot api is unavailable right now")
+
+//circuitBreaker stops us from hammering Riot while it's broken. After enough failures in a row it opens,
+//every call fails straight away, and pages fall back to the archive and cache until a probe gets through again.
+type circuitBreaker struct {
+	sync.Mutex
+	threshold int
+	cooldown  time.Duration
+
+	failures int
+	openedAt time.Time
+}
+
+var riotBreaker = &circuitBreaker{threshold: 5, cooldown: 30 * time.Second}
+
+//isOutage reports whether an error means Riot itself is in trouble, as opposed to asking for something that isn't there.
+//Server errors, network errors and a rejected API key all count. 404s and rate limits don't.
+func isOutage(err error) bool {
+
+	if err == nil {
+		return false
+	}
+	if err == errRiotUnavailable {
+		return true
+	}
+	//Our own search being cancelled isn't Riot's fault. Calls that time out still count, through the network error below.
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	//A name Riot doesn't know is Riot answering, not Riot being down
+	if errors.Is(err, errNoSummoner) {
+		return false
+	}
+
+	e, ok := err.(*apiError)
+	if !ok {
+		//Anything that isn't an answer from Riot is the network failing
+		return true
+	}
+
+	return e.Status >= 500 || e.Status == http.StatusUnauthorized || e.Status == http.StatusForbidden
+}
+
+//allow says whether a call may go to Riot right now
+func (b *circuitBreaker) allow() bool {
+	b.Lock()
+	defer b.Unlock()
+	return b.openedAt.IsZero()
+}
+
+//open reports whether the breaker has tripped
+func (b *circuitBreaker) open() bool {
+	return !b.allow()
+}
+
+//record counts the result of a call. Only outages count as failures.
+func (b *circuitBreaker) record(err error) {
+
+	b.Lock()
+	defer b.Unlock()
+
+	if !isOutage(err) {
+		if !b.openedAt.IsZero() {
+			slog.Info("breaker closed, riot is answering again")
+		}
+		b.failures = 0
+		b.openedAt = time.Time{}
+		return
+	}
+
+	b.failures++
+	if b.openedAt.IsZero() && b.failures >= b.threshold {
+		slog.Error("breaker tripped", "failures", b.failures, "err", err)
+		b.openedAt = time.Now()
+	}
+}
+
+//probeLoop checks on Riot every cooldown while the breaker is open, and closes it once a call gets through.
+//probe should be something cheap that isn't cached. It stops when ctx is done.
+func (b *circuitBreaker) probeLoop(ctx context.Context, probe func(context.Context) error) {
+
+	ticker := time.NewTicker(b.cooldown)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if !b.open() {
+				continue
+			}
+			//A probe cut short by shutting down says nothing about Riot
+			if err := probe(ctx); ctx.Err() == nil {
+				b.record(err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+//probeRiot asks the status endpoint, which is about the cheapest call there is
+func probeRiot(ctx context.Context) error {
+	_, err := riotFetchOnce(ctx, riotURL(defaultRegion, "/lol/status/v3/shard-data"))
+	return err
+}
+
+//staleGet decodes whatever the cache has for url into v, however old it is, and says when we got it
+func staleGet(url string, v interface{}) (time.Time, error) {
+
+	body, stored, ok := riotCache.stale(url)
+	if !ok {
+		return stored, errRiotUnavailable
+	}
+
+	return stored, json.Unmarshal(body, v)
+}
+
+//staleSearch builds a profile without asking Riot anything: the summoner and their rank from the cache if we have them,
+//and everything else from the archive. It only fails if we've never seen the summoner at all.
+func staleSearch(ctx context.Context, region string, name string) (*Output, error) {
+
+	out := &Output{Region: region, Stale: true}
+
+	var record Profile
+	updated, err := staleGet(summonerURL(region, name), &record)
+	if err != nil {
+		record, err = archivedProfile(region, name)
+		if err != nil {
+			return nil, err
+		}
+	}
+	out.SummonerName = record.Name
+	out.ProfileIconID = record.ProfileIconID
+	out.AccountID = record.AccountID
+	out.SummonerID = record.ID
+
+	snapshots, err := loadSnapshots(region, out.AccountID)
+	if err != nil {
+		logFrom(ctx).Warn("lp history failed", "err", err)
+	}
+
+	//The last rank we saw is either still in the cache, or is the last point on their LP graph
+	var entries []LeagueEntry
+	if stored, err := staleGet(leagueURL(region, record.ID), &entries); err == nil {
+		out.Ranked = ranked(entries)
+		if stored.After(updated) {
+			updated = stored
+		}
+	} else if len(snapshots) > 0 {
+		last := snapshots[len(snapshots)-1]
+		out.Ranked.Solo = &LeagueEntry{QueueType: "RANKED_SOLO_5x5", Tier: last.Tier, Rank: last.Rank, LeaguePoints: last.LP, Wins: last.Wins, Losses: last.Losses}
+	}
+	if len(snapshots) > 0 {
+		if synced := time.Unix(snapshots[len(snapshots)-1].Time/1000, 0); synced.After(updated) {
+			updated = synced
+		}
+	}
+
+	matches, err := loadArchive(region, out.AccountID)
+	if err != nil {
+		logFrom(ctx).Warn("archive access failed", "err", err)
+	}
+	for i := 0; i < len(matches) && i < 5; i++ {
+		m := matches[i]
+		m.decorate()
+		out.Match = append(out.Match, m)
+	}
+	archiveTables(ctx, out, matches)
+
+	out.LastUpdated = updated
+	return out, nil
+}
+
+//archivedProfile finds a summoner by name in the archive, for when Riot can't tell us who they are.
+//Only the newest match they're in counts, since names change.
+func archivedProfile(region string, name string) (Profile, error) {
+
+	x, err := indexFor(region)
+	if err != nil {
+		return Profile{}, err
+	}
+
+	if p, ok := x.player(name); ok {
+		return p, nil
+	}
+
+	return Profile{}, errNoSummoner
+}
+
+func (o Output) LastUpdatedDate() string {
+	if o.LastUpdated.IsZero() {
+		return "unknown"
+	}
+	return o.LastUpdated.Format("Jan 2, 2006 3:04 PM")
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestIsOutage(t *testing.T) {
+
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{errRiotUnavailable, true},
+		{&apiError{Status: http.StatusInternalServerError}, true},
+		{&apiError{Status: http.StatusServiceUnavailable}, true},
+		{&apiError{Status: http.StatusUnauthorized}, true},
+		{&apiError{Status: http.StatusForbidden}, true},
+		{&apiError{Status: http.StatusNotFound}, false},
+		{&apiError{Status: http.StatusTooManyRequests}, false},
+		{errors.New("dial tcp: connection refused"), true},
+		{context.DeadlineExceeded, true},
+		{context.Canceled, false},
+		{fmt.Errorf("Get: %w", context.Canceled), false},
+		{errNoSummoner, false},
+	}
+
+	for _, tt := range tests {
+		if got := isOutage(tt.err); got != tt.want {
+			t.Errorf("isOutage(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestBreakerTrips(t *testing.T) {
+
+	b := &circuitBreaker{threshold: 3, cooldown: time.Minute}
+	down := &apiError{Status: http.StatusBadGateway}
+
+	b.record(down)
+	b.record(down)
+	if b.open() {
+		t.Fatal("opened before the threshold")
+	}
+
+	//Asking for a summoner that doesn't exist is a normal answer, and starts the count again
+	b.record(&apiError{Status: http.StatusNotFound})
+	b.record(down)
+	b.record(down)
+	if b.open() {
+		t.Fatal("a 404 didn't reset the failures")
+	}
+
+	b.record(down)
+	if !b.open() || b.allow() {
+		t.Fatal("didn't open after threshold outages in a row")
+	}
+
+	//The first call that gets through closes it again
+	b.record(nil)
+	if b.open() {
+		t.Error("a call that got through didn't close it")
+	}
+}
+
+func TestBreakerProbeLoop(t *testing.T) {
+
+	b := &circuitBreaker{threshold: 1, cooldown: time.Millisecond}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var mu sync.Mutex
+	probes := 0
+	stopped := make(chan struct{})
+	go func() {
+		b.probeLoop(ctx, func(context.Context) error {
+			mu.Lock()
+			defer mu.Unlock()
+			probes++
+			//Riot is still down for the first probe, and back for the second
+			if probes == 1 {
+				return &apiError{Status: http.StatusServiceUnavailable}
+			}
+			return nil
+		})
+		close(stopped)
+	}()
+
+	b.record(errRiotUnavailable)
+	for b.open() {
+		time.Sleep(time.Millisecond)
+	}
+
+	//Once it's closed nothing more is probed
+	time.Sleep(20 * time.Millisecond)
+	mu.Lock()
+	if probes != 2 {
+		t.Errorf("probed %d times, want 2", probes)
+	}
+	mu.Unlock()
+
+	cancel()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Error("probeLoop kept going after its context was cancelled")
+	}
+}
+
+func TestSearchUnknownNameIsNotStale(t *testing.T) {
+
+	oldDir := archiveDir
+	archiveDir = t.TempDir()
+	t.Cleanup(func() { archiveDir = oldDir })
+
+	//Somebody else went by this name in an archived game
+	archiveMatch("na", 10, indexedMatch(1, 100, map[int]string{1: "Foo"}))
+
+	fakeRiot(t, func(req *http.Request) (*http.Response, error) {
+		return answer(http.StatusNotFound, `{}`), nil
+	})
+
+	out, err := summonerSearch(context.Background(), "na", "foo")
+	if err != errNoSummoner {
+		t.Errorf("got %+v, %v, want errNoSummoner rather than the archived player", out, err)
+	}
+	if riotBreaker.open() {
+		t.Error("a name Riot doesn't know tripped the breaker")
+	}
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+//forever is the TTL for responses that can never change, like a finished match
+const forever time.Duration = -1
+
+//cacheRule says how long answers from one kind of endpoint stay good. A TTL of 0 means we never cache them.
+type cacheRule struct {
+	Name string
+	Path string
+	TTL  time.Duration
+}
+
+//cacheRules are checked in order and the first one whose path is in the URL wins
+var cacheRules = []cacheRule{
+	{Name: "match", Path: "/lol/match/v3/matches/", TTL: forever},
+	//Timelines are big and already kept in archive/timelines, so the cache would only be a second copy
+	{Name: "timeline", Path: "/lol/match/v3/timelines/", TTL: 0},
+	{Name: "static-data", Path: "/lol/static-data/", TTL: 24 * time.Hour},
+	{Name: "matchlist", Path: "/lol/match/v3/matchlists/", TTL: 10 * time.Minute},
+	{Name: "mastery", Path: "/lol/champion-mastery/", TTL: 10 * time.Minute},
+	{Name: "summoner", Path: "/lol/summoner/", TTL: 2 * time.Minute},
+	{Name: "league", Path: "/lol/league/", TTL: 2 * time.Minute},
+	//Live games change every second, and a 404 today is a game tomorrow
+	{Name: "spectator", Path: "/lol/spectator/", TTL: 0},
+}
+
+//cacheRuleFor finds the rule for a URL. Anything we don't know about isn't cached.
+func cacheRuleFor(url string) cacheRule {
+
+	for _, r := range cacheRules {
+		if strings.Contains(url, r.Path) {
+			return r
+		}
+	}
+
+	return cacheRule{Name: "other"}
+}
+
+var apiKeyParam = regexp.MustCompile(`[?&]api_key=[^&]*`)
+
+//cacheKey is the URL without our API key, so the key never ends up on disk and changing keys doesn't empty the cache
+func cacheKey(url string) string {
+	return apiKeyParam.ReplaceAllString(url, "")
+}
+
+//cachedResponse is one stored answer. A zero Expires means it never expires.
+type cachedResponse struct {
+	Key     string          `json:"key"`
+	Stored  time.Time       `json:"stored"`
+	Expires time.Time       `json:"expires"`
+	Body    json.RawMessage `json:"body"`
+}
+
+func (c cachedResponse) fresh(now time.Time) bool {
+	return c.Expires.IsZero() || now.Before(c.Expires)
+}
+
+//CacheCounts is how often one kind of endpoint was answered from the cache
+type CacheCounts struct {
+	Hits     int `json:"hits"`
+	DiskHits int `json:"diskHits"`
+	Misses   int `json:"misses"`
+
+	//StaleHits are expired answers handed out while Riot wasn't answering
+	StaleHits int `json:"staleHits"`
+}
+
+//responseCache is an LRU of API responses in memory, optionally backed by a folder on disk.
+//The disk store lets finished matches survive restarts and be shared between the website and the command line.
+//It holds at most size responses and maxBytes of response bodies, whichever fills up first.
+type responseCache struct {
+	sync.Mutex
+	size     int
+	maxBytes int
+	bytes    int
+	dir      string
+	order    *list.List
+	entries  map[string]*list.Element
+	counts   map[string]*CacheCounts
+}
+
+func newResponseCache(size int, maxBytes int) *responseCache {
+	return &responseCache{
+		size:     size,
+		maxBytes: maxBytes,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+		counts:   make(map[string]*CacheCounts),
+	}
+}
+
+//riotCache sits under riotGet. Set IVERN_CACHE_DIR to also keep responses on disk.
+var riotCache = newResponseCache(1000, 64<<20)
+
+func (c *responseCache) count(rule string) *CacheCounts {
+
+	n, ok := c.counts[rule]
+	if !ok {
+		n = &CacheCounts{}
+		c.counts[rule] = n
+	}
+
+	return n
+}
+
+func (c *responseCache) diskPath(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+//get hands back the stored body for a URL if there's a fresh one, checking memory and then disk
+func (c *responseCache) get(url string) ([]byte, bool) {
+
+	rule := cacheRuleFor(url)
+	if rule.TTL == 0 {
+		return nil, false
+	}
+	key := cacheKey(url)
+	now := time.Now()
+
+	c.Lock()
+	if e, ok := c.entries[key]; ok {
+		r := e.Value.(cachedResponse)
+		if r.fresh(now) {
+			c.order.MoveToFront(e)
+			c.count(rule.Name).Hits++
+			c.Unlock()
+			return r.Body, true
+		}
+		//Expired answers stay until the LRU pushes them out, in case Riot goes down and we need them
+	}
+	dir := c.dir
+	c.Unlock()
+
+	//The disk is only read without the lock held, so one slow read doesn't hold up every other search
+	if dir != "" {
+		data, err := os.ReadFile(c.diskPath(key))
+		var r cachedResponse
+		if err == nil && json.Unmarshal(data, &r) == nil && r.Key == key && r.fresh(now) {
+			c.Lock()
+			c.add(r)
+			c.count(rule.Name).DiskHits++
+			c.Unlock()
+			return r.Body, true
+		}
+	}
+
+	c.Lock()
+	c.count(rule.Name).Misses++
+	c.Unlock()
+	return nil, false
+}
+
+//stale hands back whatever we have stored for a URL no matter how old it is, along with when we got it.
+//It's only for when Riot isn't answering, since old data is better than no page at all.
+func (c *responseCache) stale(url string) ([]byte, time.Time, bool) {
+
+	rule := cacheRuleFor(url)
+	key := cacheKey(url)
+
+	c.Lock()
+	if e, ok := c.entries[key]; ok {
+		r := e.Value.(cachedResponse)
+		c.count(rule.Name).StaleHits++
+		c.Unlock()
+		return r.Body, r.Stored, true
+	}
+	dir := c.dir
+	c.Unlock()
+
+	if dir == "" {
+		return nil, time.Time{}, false
+	}
+	data, err := os.ReadFile(c.diskPath(key))
+	var r cachedResponse
+	if err != nil || json.Unmarshal(data, &r) != nil || r.Key != key {
+		return nil, time.Time{}, false
+	}
+
+	c.Lock()
+	c.count(rule.Name).StaleHits++
+	c.Unlock()
+	return r.Body, r.Stored, true
+}
+
+//put stores a successful response for as long as its endpoint's rule allows
+func (c *responseCache) put(url string, body []byte) error {
+
+	rule := cacheRuleFor(url)
+	if rule.TTL == 0 {
+		return nil
+	}
+
+	r := cachedResponse{Key: cacheKey(url), Stored: time.Now(), Body: json.RawMessage(body)}
+	if rule.TTL != forever {
+		r.Expires = r.Stored.Add(rule.TTL)
+	}
+
+	c.Lock()
+	c.add(r)
+	dir := c.dir
+	c.Unlock()
+
+	if dir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(c.diskPath(r.Key), data)
+}
+
+//add puts a response at the front of the LRU, dropping the least recently used ones until we're back under both limits.
+//A response bigger than maxBytes on its own isn't kept in memory at all. The lock must be held.
+func (c *responseCache) add(r cachedResponse) {
+
+	if e, ok := c.entries[r.Key]; ok {
+		c.remove(e)
+	}
+	if len(r.Body) > c.maxBytes {
+		return
+	}
+
+	c.entries[r.Key] = c.order.PushFront(r)
+	c.bytes += len(r.Body)
+
+	for c.order.Len() > c.size || c.bytes > c.maxBytes {
+		c.remove(c.order.Back())
+	}
+}
+
+//remove drops one response from memory. The lock must be held.
+func (c *responseCache) remove(e *list.Element) {
+	r := c.order.Remove(e).(cachedResponse)
+	delete(c.entries, r.Key)
+	c.bytes -= len(r.Body)
+}
+
+//CacheStats is what /debug/cache shows
+type CacheStats struct {
+	Entries  int                    `json:"entries"`
+	Size     int                    `json:"size"`
+	Bytes    int                    `json:"bytes"`
+	MaxBytes int                    `json:"maxBytes"`
+	Disk     string                 `json:"disk,omitempty"`
+	Counts   map[string]CacheCounts `json:"counts"`
+	HitRate  float64                `json:"hitRate"`
+
+	//Coalesced is how many calls waited for an identical one already in flight instead of going to Riot
+	Coalesced int `json:"coalesced"`
+}
+
+func (c *responseCache) stats() CacheStats {
+
+	c.Lock()
+	defer c.Unlock()
+
+	s := CacheStats{Entries: c.order.Len(), Size: c.size, Bytes: c.bytes, MaxBytes: c.maxBytes, Disk: c.dir, Counts: make(map[string]CacheCounts)}
+	hits, total := 0, 0
+	for name, n := range c.counts {
+		s.Counts[name] = *n
+		hits += n.Hits + n.DiskHits
+		total += n.Hits + n.DiskHits + n.Misses
+	}
+	s.HitRate = percent(hits, total)
+
+	return s
+}
+
+//cacheFunc serves /debug/cache, the hit and miss counters for every kind of endpoint
+func cacheFunc(response http.ResponseWriter, request *http.Request) {
+
+	stats := riotCache.stats()
+	stats.Coalesced = riotFlights.shared()
+
+	response.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(response).Encode(stats)
+
+}
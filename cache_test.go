@@ -0,0 +1,148 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCacheRuleFor(t *testing.T) {
+
+	tests := []struct {
+		url  string
+		rule string
+		ttl  time.Duration
+	}{
+		{"https://na1.api.riotgames.com/lol/match/v3/matches/1", "match", forever},
+		{"https://na1.api.riotgames.com/lol/match/v3/timelines/by-match/1", "timeline", 0},
+		{"https://na1.api.riotgames.com/lol/match/v3/matchlists/by-account/1", "matchlist", 10 * time.Minute},
+		{"https://na1.api.riotgames.com/lol/summoner/v3/summoners/by-name/x", "summoner", 2 * time.Minute},
+		{"https://na1.api.riotgames.com/lol/spectator/v3/active-games/by-summoner/1", "spectator", 0},
+		{"https://na1.api.riotgames.com/lol/status/v3/shard-data", "other", 0},
+	}
+
+	for _, tt := range tests {
+		r := cacheRuleFor(tt.url)
+		if r.Name != tt.rule || r.TTL != tt.ttl {
+			t.Errorf("cacheRuleFor(%q) = %s %v, want %s %v", tt.url, r.Name, r.TTL, tt.rule, tt.ttl)
+		}
+	}
+}
+
+func TestCacheKeyDropsAPIKey(t *testing.T) {
+
+	tests := map[string]string{
+		"https://x/lol/match/v3/matches/1?api_key=SECRET":         "https://x/lol/match/v3/matches/1",
+		"https://x/lol/match/v3/matchlists/1?queue=420&api_key=S": "https://x/lol/match/v3/matchlists/1?queue=420",
+		"https://x/lol/match/v3/matches/1":                        "https://x/lol/match/v3/matches/1",
+	}
+
+	for url, want := range tests {
+		if got := cacheKey(url); got != want {
+			t.Errorf("cacheKey(%q) = %q, want %q", url, got, want)
+		}
+	}
+}
+
+func matchURL(id string) string {
+	return "https://na1.api.riotgames.com/lol/match/v3/matches/" + id
+}
+
+func TestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+
+	c := newResponseCache(2, 1<<20)
+	c.put(matchURL("1"), []byte(`1`))
+	c.put(matchURL("2"), []byte(`2`))
+
+	//Using 1 makes 2 the oldest, so 2 is what makes room for 3
+	c.get(matchURL("1"))
+	c.put(matchURL("3"), []byte(`3`))
+
+	if _, ok := c.get(matchURL("2")); ok {
+		t.Error("2 should have been evicted")
+	}
+	for _, id := range []string{"1", "3"} {
+		if _, ok := c.get(matchURL(id)); !ok {
+			t.Errorf("%s should still be cached", id)
+		}
+	}
+}
+
+func TestCacheEvictsByBytes(t *testing.T) {
+
+	c := newResponseCache(100, 10)
+	c.put(matchURL("1"), []byte(`"aaaa"`))
+	c.put(matchURL("2"), []byte(`"bbbb"`))
+
+	if _, ok := c.get(matchURL("1")); ok {
+		t.Error("1 should have been evicted to stay under maxBytes")
+	}
+	if s := c.stats(); s.Entries != 1 || s.Bytes != 6 {
+		t.Errorf("got %d entries and %d bytes, want 1 and 6", s.Entries, s.Bytes)
+	}
+
+	//Something bigger than the whole cache isn't kept at all, and doesn't push anything else out for nothing
+	c.put(matchURL("3"), []byte(`"`+strings.Repeat("c", 20)+`"`))
+	if _, ok := c.get(matchURL("2")); !ok {
+		t.Error("an oversized answer pushed 2 out")
+	}
+	if s := c.stats(); s.Entries != 1 || s.Bytes != 6 {
+		t.Errorf("got %d entries and %d bytes after an oversized put, want 1 and 6", s.Entries, s.Bytes)
+	}
+
+	//Replacing an entry counts its new size, not both
+	c.put(matchURL("2"), []byte(`"bbbbbb"`))
+	if s := c.stats(); s.Entries != 1 || s.Bytes != 8 {
+		t.Errorf("got %d entries and %d bytes after replacing, want 1 and 8", s.Entries, s.Bytes)
+	}
+}
+
+func TestCacheTTL(t *testing.T) {
+
+	c := newResponseCache(10, 1<<20)
+
+	//Endpoints with no TTL are never stored
+	c.put("https://na1.api.riotgames.com/lol/match/v3/timelines/by-match/1", []byte(`{}`))
+	if s := c.stats(); s.Entries != 0 {
+		t.Errorf("timeline was cached")
+	}
+
+	//An expired answer is a miss, but is still there for when Riot is down
+	url := "https://na1.api.riotgames.com/lol/summoner/v3/summoners/by-name/x"
+	c.Lock()
+	c.add(cachedResponse{Key: cacheKey(url), Stored: time.Now().Add(-time.Hour), Expires: time.Now().Add(-time.Minute), Body: []byte(`{}`)})
+	c.Unlock()
+
+	if _, ok := c.get(url); ok {
+		t.Error("expired answer was a hit")
+	}
+	if _, _, ok := c.stale(url); !ok {
+		t.Error("expired answer wasn't kept for stale lookups")
+	}
+
+	counts := c.stats().Counts["summoner"]
+	if counts.Misses != 1 || counts.StaleHits != 1 {
+		t.Errorf("counts = %+v, want 1 miss and 1 stale hit", counts)
+	}
+}
+
+func TestCacheDisk(t *testing.T) {
+
+	dir := t.TempDir()
+	c := newResponseCache(10, 1<<20)
+	c.dir = dir
+	if err := c.put(matchURL("1")+"?api_key=SECRET", []byte(`{"gameId":1}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	//A fresh cache on the same folder, like the next run of the command line, finds it on disk
+	next := newResponseCache(10, 1<<20)
+	next.dir = dir
+	body, ok := next.get(matchURL("1") + "?api_key=OTHER")
+	if !ok || string(body) != `{"gameId":1}` {
+		t.Fatalf("got %s, %v from disk", body, ok)
+	}
+	if n := next.stats().Counts["match"].DiskHits; n != 1 {
+		t.Errorf("disk hits = %d, want 1", n)
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+//ChampionStats is one row of the champion table: every archived game a summoner played on a single champion, added up.
+type ChampionStats struct {
+	Champion   int
+	Name       string
+	Games      int
+	Wins       int
+	Kills      int
+	Deaths     int
+	Assists    int
+	CS         int
+	Damage     int
+	Seconds    int
+	LastPlayed int64
+}
+
+//ChampionTable is everything the "championTable" template needs to draw and re-sort the table
+type ChampionTable struct {
+	SummonerName string
+	AccountID    int
+	MinGames     int
+	Champions    []ChampionStats
+}
+
+//The ways the champion table can be ordered. Anything else falls back to "games".
+var championSorts = []string{"games", "winrate", "kda", "cs", "damage", "recent"}
+
+func (c ChampionStats) WinRate() float64 {
+	if c.Games == 0 {
+		return 0
+	}
+	return 100 * float64(c.Wins) / float64(c.Games)
+}
+
+//KDA follows the usual (kills + assists) / deaths, treating a deathless record as if the player died once
+func (c ChampionStats) KDA() float64 {
+	deaths := c.Deaths
+	if deaths == 0 {
+		deaths = 1
+	}
+	return float64(c.Kills+c.Assists) / float64(deaths)
+}
+
+func (c ChampionStats) CSPerMin() float64 {
+	if c.Seconds == 0 {
+		return 0
+	}
+	return float64(c.CS) / (float64(c.Seconds) / 60)
+}
+
+func (c ChampionStats) DamagePerMin() float64 {
+	if c.Seconds == 0 {
+		return 0
+	}
+	return float64(c.Damage) / (float64(c.Seconds) / 60)
+}
+
+func (c ChampionStats) LastPlayedDate() string {
+	return time.Unix(c.LastPlayed/1000, 0).Format("Jan 2, 2006")
+}
+
+//championBreakdown groups a summoner's archived matches by champion, drops champions played fewer than minGames times,
+//and orders what's left by sortBy.
+func championBreakdown(matches []MatchInfo, sortBy string, minGames int) []ChampionStats {
+
+	byChampion := make(map[int]*ChampionStats)
+
+	for _, m := range matches {
+		p, ok := m.player()
+		if !ok {
+			continue
+		}
+
+		c, ok := byChampion[m.Champion]
+		if !ok {
+			c = &ChampionStats{Champion: m.Champion}
+			byChampion[m.Champion] = c
+		}
+
+		c.Games++
+		if p.Stats.Win {
+			c.Wins++
+		}
+		c.Kills += p.Stats.Kills
+		c.Deaths += p.Stats.Deaths
+		c.Assists += p.Stats.Assists
+		c.CS += p.Derived.CS
+		c.Damage += p.Stats.TotalDamageDealtToChampions
+		c.Seconds += m.Stats.GameDuration
+
+		//The newest game on a champion gives us its name and when it was last played
+		if m.Timestamp > c.LastPlayed {
+			c.LastPlayed = m.Timestamp
+			c.Name = m.Name
+		}
+	}
+
+	table := make([]ChampionStats, 0, len(byChampion))
+	for _, c := range byChampion {
+		if c.Games >= minGames {
+			table = append(table, *c)
+		}
+	}
+
+	var less func(a, b ChampionStats) bool
+	switch sortBy {
+	case "winrate":
+		less = func(a, b ChampionStats) bool { return a.WinRate() > b.WinRate() }
+	case "kda":
+		less = func(a, b ChampionStats) bool { return a.KDA() > b.KDA() }
+	case "cs":
+		less = func(a, b ChampionStats) bool { return a.CSPerMin() > b.CSPerMin() }
+	case "damage":
+		less = func(a, b ChampionStats) bool { return a.DamagePerMin() > b.DamagePerMin() }
+	case "recent":
+		less = func(a, b ChampionStats) bool { return a.LastPlayed > b.LastPlayed }
+	default:
+		less = func(a, b ChampionStats) bool { return a.Games > b.Games }
+	}
+
+	//Ties are broken by games played, then by name, so the table doesn't shuffle around between page loads
+	sort.Slice(table, func(i, j int) bool {
+		a, b := table[i], table[j]
+		if less(a, b) != less(b, a) {
+			return less(a, b)
+		}
+		if a.Games != b.Games {
+			return a.Games > b.Games
+		}
+		return a.Name < b.Name
+	})
+
+	return table
+}
+
+//championFunc serves /champions?account=<id>&name=<name>&sort=<column>&min=<games>, the full champion table for one summoner.
+//It only reads from the archive, so re-sorting never costs us any API calls.
+func championFunc(response http.ResponseWriter, request *http.Request) {
+
+	accountID, err := strconv.Atoi(request.FormValue("account"))
+	if err != nil {
+		http.Error(response, "account must be a number", http.StatusBadRequest)
+		return
+	}
+
+	minGames, err := strconv.Atoi(request.FormValue("min"))
+	if err != nil || minGames < 1 {
+		minGames = 1
+	}
+
+	matches, err := loadArchive(defaultRegion, accountID)
+	if err != nil {
+		http.Error(response, "Could not read the match archive", http.StatusInternalServerError)
+		return
+	}
+
+	page := struct {
+		ChampionTable
+		Sort  string
+		Sorts []string
+	}{
+		ChampionTable: ChampionTable{
+			SummonerName: request.FormValue("name"),
+			AccountID:    accountID,
+			MinGames:     minGames,
+			Champions:    championBreakdown(matches, request.FormValue("sort"), minGames),
+		},
+		Sort:  request.FormValue("sort"),
+		Sorts: championSorts,
+	}
+
+	renderPage(request.Context(), response, "champions", page)
+
+}
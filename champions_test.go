@@ -0,0 +1,114 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+//played is a game on a champion, where the summoner went kills/deaths/assists over ten minutes
+func played(champion int, name string, timestamp int64, win bool, kills, deaths, assists int) MatchInfo {
+
+	m := MatchInfo{Champion: champion, Name: name, Timestamp: timestamp}
+	m.Stats.ParticipantID = 1
+	m.Stats.GameDuration = 600
+
+	var p Participant
+	p.ParticipantID = 1
+	p.ChampionID = champion
+	p.Stats.Win = win
+	p.Stats.Kills, p.Stats.Deaths, p.Stats.Assists = kills, deaths, assists
+	m.Stats.Participants = []Participant{p}
+	return m
+}
+
+func TestChampionBreakdown(t *testing.T) {
+
+	matches := []MatchInfo{
+		played(103, "Ahri", 1, true, 10, 2, 5),
+		played(103, "Ahri", 5, false, 2, 6, 1),
+		played(103, "Ahri", 3, true, 4, 0, 8),
+		played(1, "Annie", 4, true, 6, 1, 6),
+		played(1, "Annie", 2, true, 3, 3, 3),
+		played(99, "Lux", 6, false, 0, 5, 10),
+	}
+
+	names := func(table []ChampionStats) []string {
+		var got []string
+		for _, c := range table {
+			got = append(got, c.Name)
+		}
+		return got
+	}
+
+	tests := []struct {
+		sortBy   string
+		minGames int
+		want     []string
+	}{
+		{"games", 1, []string{"Ahri", "Annie", "Lux"}},
+		{"winrate", 1, []string{"Annie", "Ahri", "Lux"}},
+		//Annie is 18/4, Ahri 30/8, Lux 10/5
+		{"kda", 1, []string{"Annie", "Ahri", "Lux"}},
+		{"recent", 1, []string{"Lux", "Ahri", "Annie"}},
+		{"games", 2, []string{"Ahri", "Annie"}},
+		{"nonsense", 3, []string{"Ahri"}},
+	}
+
+	for _, tt := range tests {
+		if got := names(championBreakdown(matches, tt.sortBy, tt.minGames)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("sorted by %s with %d games: got %v, want %v", tt.sortBy, tt.minGames, got, tt.want)
+		}
+	}
+
+	ahri := championBreakdown(matches, "games", 1)[0]
+	want := ChampionStats{Champion: 103, Name: "Ahri", Games: 3, Wins: 2, Kills: 16, Deaths: 8, Assists: 14, Seconds: 1800, LastPlayed: 5}
+	if ahri != want {
+		t.Errorf("got %+v, want %+v", ahri, want)
+	}
+}
+
+func TestChampionBreakdownTies(t *testing.T) {
+
+	//Every champion has won once, so winrate ties are broken by games played and then by name
+	matches := []MatchInfo{
+		played(2, "Zed", 1, true, 1, 1, 1),
+		played(3, "Lux", 2, true, 1, 1, 1),
+		played(4, "Ahri", 3, true, 1, 1, 1),
+		played(4, "Ahri", 4, true, 1, 1, 1),
+	}
+
+	var got []string
+	for _, c := range championBreakdown(matches, "winrate", 1) {
+		got = append(got, c.Name)
+	}
+	if want := []string{"Ahri", "Lux", "Zed"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	//The same matches in any order give the same table
+	reversed := []MatchInfo{matches[3], matches[2], matches[1], matches[0]}
+	if again := championBreakdown(reversed, "winrate", 1); !reflect.DeepEqual(again, championBreakdown(matches, "winrate", 1)) {
+		t.Error("the table depends on the order of the matches")
+	}
+}
+
+func TestChampionStatsRates(t *testing.T) {
+
+	c := ChampionStats{Games: 4, Wins: 3, Kills: 10, Deaths: 0, Assists: 5, CS: 300, Damage: 30000, Seconds: 1200}
+	if got := c.WinRate(); got != 75 {
+		t.Errorf("WinRate = %v, want 75", got)
+	}
+	//No deaths counts as one
+	if got := c.KDA(); got != 15 {
+		t.Errorf("KDA = %v, want 15", got)
+	}
+	if got := c.CSPerMin(); got != 15 {
+		t.Errorf("CSPerMin = %v, want 15", got)
+	}
+	if got := c.DamagePerMin(); got != 1500 {
+		t.Errorf("DamagePerMin = %v, want 1500", got)
+	}
+	if got := (ChampionStats{}).CSPerMin(); got != 0 {
+		t.Errorf("CSPerMin with no games = %v, want 0", got)
+	}
+}
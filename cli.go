@@ -0,0 +1,299 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const usage = `usage: ivern <command> [arguments]
+
+commands:
+  serve [-addr :8080]                     start the website (the default with no command)
+        [-cert file -key file]            serve HTTPS, reloading the certificate when it changes
+        [-redirect :80]                   with -cert, redirect plain HTTP to HTTPS
+        [-templates dir]                  restyle pages with templates from dir, see templates/ for the layout
+  lookup <region> <name>                  print a summoner's rank and recent games
+  sync [-region na] [-games 20] <name>    archive a summoner's recent games and rank
+  export -account <id> [-format csv]      write archived games to standard output, see export -h for filters
+         [-region na]                     the region the account plays on
+  import [-region na] <file.ndjson>       add games from an NDJSON export to the archive, - reads standard input
+
+Set IVERN_CACHE_DIR to keep Riot API responses on disk between runs, and IVERN_ARCHIVE_DIR to keep the
+archive somewhere other than ./archive.
+IVERN_TLS_CERT, IVERN_TLS_KEY and IVERN_TEMPLATE_DIR can stand in for serve's -cert, -key and -templates.`
+
+//runCommand picks the subcommand out of the command line arguments (without the program name).
+//Every command uses the same Riot client and archive as the website, so scripts and cron jobs don't need the server running.
+func runCommand(args []string) error {
+
+	setupLogging()
+	setupTracing()
+	defer tracer.flush()
+
+	//Keeping API responses on disk is optional, and shared by every command that points at the same folder
+	riotCache.dir = os.Getenv("IVERN_CACHE_DIR")
+
+	//Like the cache, the archive can be moved, as long as the website and the commands all point at the same folder
+	if dir := os.Getenv("IVERN_ARCHIVE_DIR"); dir != "" {
+		archiveDir = dir
+	}
+
+	if len(args) == 0 {
+		return serveCommand(nil)
+	}
+
+	switch args[0] {
+	case "serve":
+		return serveCommand(args[1:])
+	case "lookup":
+		return lookupCommand(args[1:])
+	case "sync":
+		return syncCommand(args[1:])
+	case "export":
+		return exportCommand(args[1:])
+	case "import":
+		return importCommand(args[1:])
+	case "help", "-h", "-help", "--help":
+		fmt.Println(usage)
+		return nil
+	}
+
+	return fmt.Errorf("unknown command %q\n%s", args[0], usage)
+}
+
+//serveCommand is `ivern serve`. The certificate, key and template folder can also come from IVERN_TLS_CERT, IVERN_TLS_KEY
+//and IVERN_TEMPLATE_DIR, which is handier than flags in a container.
+func serveCommand(args []string) error {
+
+	var config serverConfig
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	flags.StringVar(&config.Addr, "addr", "", "address to listen on (default :8080, or :8443 with -cert)")
+	flags.StringVar(&config.CertFile, "cert", os.Getenv("IVERN_TLS_CERT"), "TLS certificate file, which turns on HTTPS")
+	flags.StringVar(&config.KeyFile, "key", os.Getenv("IVERN_TLS_KEY"), "TLS key file")
+	flags.StringVar(&config.RedirectAddr, "redirect", "", "with -cert, also listen for plain HTTP here and redirect it to HTTPS")
+	flags.StringVar(&config.Templates, "templates", os.Getenv("IVERN_TEMPLATE_DIR"), "folder of templates to use instead of the built in ones, file by file")
+	flags.Parse(args)
+
+	if config.Addr == "" {
+		config.Addr = ":8080"
+		if config.CertFile != "" {
+			config.Addr = ":8443"
+		}
+	}
+
+	return serve(config)
+}
+
+//checkRegion makes sure a region is one riotURL knows about, instead of quietly searching NA
+func checkRegion(region string) error {
+
+	if _, ok := platforms[region]; ok {
+		return nil
+	}
+
+	var known []string
+	for r := range platforms {
+		known = append(known, r)
+	}
+	sort.Strings(known)
+	return fmt.Errorf("unknown region %q, use one of %s", region, strings.Join(known, ", "))
+}
+
+//lookupCommand is `ivern lookup <region> <name>`, the same search as the website printed as a table.
+//Summoner names can have spaces, so everything after the region is the name.
+func lookupCommand(args []string) error {
+
+	if len(args) < 2 {
+		return fmt.Errorf("usage: ivern lookup <region> <name>")
+	}
+
+	region := strings.ToLower(args[0])
+	if err := checkRegion(region); err != nil {
+		return err
+	}
+
+	out, err := summonerSearch(context.Background(), region, strings.Join(args[1:], " "))
+	if err != nil {
+		return err
+	}
+
+	printLookup(os.Stdout, out)
+	return nil
+}
+
+//printLookup writes the summoner's rank and recent games as a plain text table
+func printLookup(w io.Writer, out *Output) {
+
+	fmt.Fprintf(w, "%s (%s)\n", out.SummonerName, strings.ToUpper(out.Region))
+	if solo := out.Ranked.Solo; solo != nil {
+		fmt.Fprintf(w, "Solo Queue: %s %d LP, %d wins %d losses\n", solo.Division(), solo.LeaguePoints, solo.Wins, solo.Losses)
+	} else {
+		fmt.Fprintln(w, "Solo Queue: Unranked")
+	}
+	if out.LiveGame != nil {
+		fmt.Fprintf(w, "In game right now (%d minutes)\n", out.LiveGame.Minutes())
+	}
+	fmt.Fprintln(w)
+
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "DATE\tCHAMPION\tPOSITION\tRESULT\tK/D/A\tCS\tLENGTH")
+	for _, m := range out.Match {
+		date := time.Unix(m.Timestamp/1000, 0).Format("Jan 2 15:04")
+		length := fmt.Sprintf("%d:%02d", m.Stats.GameDuration/60, m.Stats.GameDuration%60)
+
+		p, ok := m.player()
+		if !ok {
+			fmt.Fprintf(table, "%s\t%s\t%s\t?\t?\t?\t%s\n", date, m.Name, m.Position(), length)
+			continue
+		}
+
+		result := "Defeat"
+		if p.Stats.Win {
+			result = "Victory"
+		}
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%d/%d/%d\t%d\t%s\n", date, m.Name, m.Position(), result,
+			p.Stats.Kills, p.Stats.Deaths, p.Stats.Assists, p.Derived.CS, length)
+	}
+	table.Flush()
+}
+
+//syncCommand is `ivern sync <name>`: record the summoner's rank, and archive any recent games we don't have yet
+func syncCommand(args []string) error {
+
+	flags := flag.NewFlagSet("sync", flag.ExitOnError)
+	region := flags.String("region", defaultRegion, "region the summoner plays on")
+	games := flags.Int("games", 20, "how many recent games to check, at most 100")
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		return fmt.Errorf("usage: ivern sync [-region na] [-games 20] <name>")
+	}
+	if err := checkRegion(*region); err != nil {
+		return err
+	}
+	if *games < 1 || *games > 100 {
+		return fmt.Errorf("-games must be between 1 and 100")
+	}
+
+	name := strings.Join(flags.Args(), " ")
+	added, err := syncSummoner(context.Background(), *region, name, *games)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s: %d new games archived\n", name, added)
+	return nil
+}
+
+//syncSummoner does the archiving half of a search, without building anything for a page.
+//Games already in the archive are skipped, since finished matches never change.
+func syncSummoner(ctx context.Context, region string, name string, games int) (int, error) {
+
+	record, err := findSummoner(ctx, region, name)
+	if err != nil {
+		return 0, err
+	}
+
+	out := &Output{
+		Region:       region,
+		SummonerName: record.Name,
+		AccountID:    record.AccountID,
+		SummonerID:   record.ID,
+	}
+
+	//Like a search, a sync without the rank still archives the games, there's just no new point on the LP graph
+	entries, err := getLeagueEntries(ctx, region, record.ID)
+	if err != nil {
+		logFrom(ctx).Warn("league entries lookup failed", "err", err)
+	}
+	if err := recordSnapshot(region, out.AccountID, ranked(entries), time.Now()); err != nil {
+		return 0, err
+	}
+
+	if err := getMatchList(ctx, out, games); err != nil {
+		return 0, err
+	}
+
+	added := 0
+	for i := range out.Match {
+		if hasMatch(region, out.AccountID, out.Match[i].GameID) {
+			continue
+		}
+		if err := getChampionName(ctx, out, i); err != nil {
+			logFrom(ctx).Warn("champion name lookup failed", "err", err)
+		}
+		//getMatchInfo is what writes the match (and its timeline) to the archive
+		if err := getMatchInfo(ctx, out, i); err != nil {
+			return added, err
+		}
+		added++
+	}
+
+	return added, nil
+}
+
+//importCommand is `ivern import <file>`, which reads matches back in from an NDJSON export.
+//Each match is stored under the account it was exported for, so an export from one machine can be loaded into another.
+//Account IDs only mean something within a region, so the region has to be the one the export came from.
+func importCommand(args []string) error {
+
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	region := flags.String("region", defaultRegion, "region the exported accounts play on")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: ivern import [-region na] <file.ndjson>")
+	}
+	if err := checkRegion(*region); err != nil {
+		return err
+	}
+
+	var r io.Reader = os.Stdin
+	if file := flags.Arg(0); file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	imported, skipped := 0, 0
+	dec := json.NewDecoder(r)
+	for {
+		var m MatchInfo
+		if err := dec.Decode(&m); err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("after %d matches: %v", imported, err)
+		}
+
+		//Matches where we never found the summoner don't say whose they were, so there's nowhere to put them
+		accountID := 0
+		for _, id := range m.Stats.ParticipantIdentities {
+			if id.ParticipantID == m.Stats.ParticipantID && !m.Unmatched {
+				accountID = id.Player.AccountID
+			}
+		}
+		if accountID == 0 || m.GameID == 0 {
+			skipped++
+			continue
+		}
+
+		if err := archiveMatch(*region, accountID, m); err != nil {
+			return err
+		}
+		imported++
+	}
+
+	fmt.Printf("Imported %d matches, skipped %d\n", imported, skipped)
+	return nil
+}
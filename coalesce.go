@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+//flight is one upstream call that any number of searches can be waiting on
+type flight struct {
+	done chan struct{}
+	body []byte
+	err  error
+}
+
+//flightGroup makes sure only one call per key is ever running at a time.
+//When three people search the same player right after a game, the first search asks Riot and the other two wait for its answer.
+type flightGroup struct {
+	sync.Mutex
+	calls map[string]*flight
+
+	//coalesced counts the calls that were answered by somebody else's request
+	coalesced int
+}
+
+//riotFlights sits between riotGet and Riot, keyed by the URL without the API key
+var riotFlights = &flightGroup{calls: make(map[string]*flight)}
+
+//do runs fn for key, unless it's already running, in which case it waits and hands back that call's answer instead.
+//The body is shared between every caller, so nobody may change it. A waiter whose ctx is done stops waiting.
+//shared says whether the answer came from somebody else's call rather than our own fn.
+func (g *flightGroup) do(ctx context.Context, key string, fn func() ([]byte, error)) (body []byte, err error, shared bool) {
+
+	g.Lock()
+	if f, ok := g.calls[key]; ok {
+		g.coalesced++
+		g.Unlock()
+		select {
+		case <-f.done:
+			return f.body, f.err, true
+		case <-ctx.Done():
+			return nil, ctx.Err(), false
+		}
+	}
+
+	//If fn panics, the waiters still get an error rather than an empty answer
+	f := &flight{done: make(chan struct{}), err: errors.New("riot api: request did not finish")}
+	g.calls[key] = f
+	g.Unlock()
+
+	//Whatever happens in fn, the waiters have to be let go and the key freed for the next call
+	defer func() {
+		g.Lock()
+		delete(g.calls, key)
+		g.Unlock()
+		close(f.done)
+	}()
+
+	f.body, f.err = fn()
+	return f.body, f.err, false
+}
+
+//shared is how many calls have been answered by another caller's request so far
+func (g *flightGroup) shared() int {
+	g.Lock()
+	defer g.Unlock()
+	return g.coalesced
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+//startLeader begins a call for key that doesn't finish until release is closed, and waits until it's in flight
+func startLeader(t *testing.T, g *flightGroup, key string, release chan struct{}, fn func() ([]byte, error)) chan []byte {
+
+	done := make(chan []byte, 1)
+	go func() {
+		body, err, shared := g.do(context.Background(), key, func() ([]byte, error) {
+			<-release
+			return fn()
+		})
+		if shared {
+			t.Error("the leader's answer came back as shared")
+		}
+		if err != nil {
+			body = nil
+		}
+		done <- body
+	}()
+
+	for g.inFlight() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	return done
+}
+
+func TestFlightGroupShares(t *testing.T) {
+
+	g := &flightGroup{calls: make(map[string]*flight)}
+	release := make(chan struct{})
+	calls := 0
+	leader := startLeader(t, g, "a", release, func() ([]byte, error) {
+		calls++
+		return []byte(`answer`), nil
+	})
+
+	const waiters = 3
+	var wg sync.WaitGroup
+	for i := 0; i < waiters; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			body, err, shared := g.do(context.Background(), "a", func() ([]byte, error) {
+				t.Error("a waiter ran its own call")
+				return nil, nil
+			})
+			if string(body) != "answer" || err != nil || !shared {
+				t.Errorf("waiter got %s, %v, shared %v", body, err, shared)
+			}
+		}()
+	}
+	for g.shared() < waiters {
+		time.Sleep(time.Millisecond)
+	}
+
+	close(release)
+	wg.Wait()
+	if body := <-leader; string(body) != "answer" {
+		t.Errorf("leader got %s", body)
+	}
+	if calls != 1 {
+		t.Errorf("fn ran %d times, want 1", calls)
+	}
+
+	//Once it's finished the key is free, so the next call asks again instead of reusing an old answer
+	if n := g.inFlight(); n != 0 {
+		t.Errorf("%d calls still in flight", n)
+	}
+	body, _, shared := g.do(context.Background(), "a", func() ([]byte, error) { return []byte(`fresh`), nil })
+	if string(body) != "fresh" || shared {
+		t.Errorf("next call got %s, shared %v, want its own answer", body, shared)
+	}
+}
+
+func TestFlightGroupKeysAreSeparate(t *testing.T) {
+
+	g := &flightGroup{calls: make(map[string]*flight)}
+	release := make(chan struct{})
+	defer close(release)
+	startLeader(t, g, "a", release, func() ([]byte, error) { return nil, nil })
+
+	body, err, shared := g.do(context.Background(), "b", func() ([]byte, error) { return []byte(`b`), nil })
+	if string(body) != "b" || err != nil || shared {
+		t.Errorf("b got %s, %v, shared %v, want its own answer without waiting on a", body, err, shared)
+	}
+}
+
+func TestFlightGroupWaiterGivesUp(t *testing.T) {
+
+	g := &flightGroup{calls: make(map[string]*flight)}
+	release := make(chan struct{})
+	leader := startLeader(t, g, "a", release, func() ([]byte, error) { return []byte(`late`), nil })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	body, err, shared := g.do(ctx, "a", func() ([]byte, error) { return nil, nil })
+	if body != nil || !errors.Is(err, context.Canceled) || shared {
+		t.Errorf("cancelled waiter got %s, %v, shared %v", body, err, shared)
+	}
+
+	//Giving up doesn't take the leader down with it
+	close(release)
+	if body := <-leader; string(body) != "late" {
+		t.Errorf("leader got %s after a waiter gave up", body)
+	}
+}
+
+func TestFlightGroupPanic(t *testing.T) {
+
+	g := &flightGroup{calls: make(map[string]*flight)}
+	release := make(chan struct{})
+
+	go func() {
+		defer func() { recover() }()
+		g.do(context.Background(), "a", func() ([]byte, error) {
+			<-release
+			panic("boom")
+		})
+	}()
+	for g.inFlight() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	waiter := make(chan error, 1)
+	go func() {
+		_, err, _ := g.do(context.Background(), "a", func() ([]byte, error) { return nil, nil })
+		waiter <- err
+	}()
+	for g.shared() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	close(release)
+	if err := <-waiter; err == nil {
+		t.Error("waiter got no error from a call that panicked")
+	}
+	if n := g.inFlight(); n != 0 {
+		t.Errorf("%d calls still in flight after a panic", n)
+	}
+}
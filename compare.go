@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+//summarize adds up every game in matches into one ChampionStats, as if they were all played on the same champion
+func summarize(matches []MatchInfo) ChampionStats {
+
+	var total ChampionStats
+	for _, c := range championBreakdown(matches, "games", 1) {
+		total.Games += c.Games
+		total.Wins += c.Wins
+		total.Kills += c.Kills
+		total.Deaths += c.Deaths
+		total.Assists += c.Assists
+		total.CS += c.CS
+		total.Damage += c.Damage
+		total.Seconds += c.Seconds
+		if c.LastPlayed > total.LastPlayed {
+			total.LastPlayed = c.LastPlayed
+		}
+	}
+
+	return total
+}
+
+//CompareRow is one line of a comparison: the same number for both summoners, and how far apart they are
+type CompareRow struct {
+	Label  string
+	A      float64
+	B      float64
+	Format string
+}
+
+func (r CompareRow) Diff() float64 {
+	return r.A - r.B
+}
+
+//compareRows lines up the numbers we compare for any two sets of games
+func compareRows(a ChampionStats, b ChampionStats) []CompareRow {
+	return []CompareRow{
+		{Label: "Games", A: float64(a.Games), B: float64(b.Games), Format: "%.0f"},
+		{Label: "Win Rate", A: a.WinRate(), B: b.WinRate(), Format: "%.0f%%"},
+		{Label: "KDA", A: a.KDA(), B: b.KDA(), Format: "%.2f"},
+		{Label: "CS/min", A: a.CSPerMin(), B: b.CSPerMin(), Format: "%.1f"},
+		{Label: "Damage/min", A: a.DamagePerMin(), B: b.DamagePerMin(), Format: "%.0f"},
+	}
+}
+
+//CompareSection is a titled group of rows, like one position or one shared champion
+type CompareSection struct {
+	Title string
+	Rows  []CompareRow
+}
+
+//SharedGame is an archived game both summoners played in
+type SharedGame struct {
+	GameID    int64
+	Timestamp int64
+	Together  bool
+	ChampionA string
+	ChampionB string
+
+	//WinA is whether the first summoner won. When they played together that's a win for both.
+	WinA bool
+}
+
+func (g SharedGame) Date() string {
+	return time.Unix(g.Timestamp/1000, 0).Format("Jan 2, 2006")
+}
+
+//sharedGames finds every game both summoners played in, and whether they were on the same team.
+//matches can be stored under anyone's account, since each summoner is found in them by summoner ID.
+func sharedGames(matches []MatchInfo, aSummonerID int, bSummonerID int) []SharedGame {
+
+	var shared []SharedGame
+	for _, m := range matches {
+		ma, ok := m.as(aSummonerID)
+		if !ok {
+			continue
+		}
+		mb, ok := m.as(bSummonerID)
+		if !ok {
+			continue
+		}
+		pa, _ := ma.player()
+		pb, _ := mb.player()
+
+		shared = append(shared, SharedGame{
+			GameID:    m.GameID,
+			Timestamp: m.Timestamp,
+			Together:  pa.TeamID == pb.TeamID,
+			ChampionA: ma.Name,
+			ChampionB: mb.Name,
+			WinA:      pa.Stats.Win,
+		})
+	}
+
+	return shared
+}
+
+//Comparison is everything on the /compare page
+type Comparison struct {
+	A, B     *Output
+	Overall  []CompareRow
+	Roles    []CompareSection
+	Shared   []CompareSection
+	Together []SharedGame
+}
+
+func compare(ctx context.Context, a *Output, b *Output) Comparison {
+
+	ma, err := loadArchive(a.Region, a.AccountID)
+	if err != nil {
+		logFrom(ctx).Warn("archive access failed", "err", err)
+	}
+	mb, err := loadArchive(b.Region, b.AccountID)
+	if err != nil {
+		logFrom(ctx).Warn("archive access failed", "err", err)
+	}
+
+	c := Comparison{A: a, B: b}
+	c.Overall = compareRows(summarize(ma), summarize(mb))
+
+	byPosition := func(matches []MatchInfo, pos string) []MatchInfo {
+		var games []MatchInfo
+		for _, m := range matches {
+			if m.Position() == pos {
+				games = append(games, m)
+			}
+		}
+		return games
+	}
+
+	for _, pos := range positions {
+		pa, pb := byPosition(ma, pos), byPosition(mb, pos)
+		if len(pa) == 0 && len(pb) == 0 {
+			continue
+		}
+		c.Roles = append(c.Roles, CompareSection{Title: pos, Rows: compareRows(summarize(pa), summarize(pb))})
+	}
+
+	//Shared champions go in the order of the first summoner's champion table, most played first
+	champsB := make(map[int]ChampionStats)
+	for _, champ := range championBreakdown(mb, "games", 1) {
+		champsB[champ.Champion] = champ
+	}
+	for _, champ := range championBreakdown(ma, "games", 1) {
+		if other, ok := champsB[champ.Champion]; ok {
+			c.Shared = append(c.Shared, CompareSection{Title: champ.Name, Rows: compareRows(champ, other)})
+		}
+	}
+
+	//A game they played together may only be stored under one of them, so look through both archives
+	together, err := loadArchivesWith(a.Region, a.SummonerID, b.SummonerID)
+	if err != nil {
+		logFrom(ctx).Warn("archive access failed", "err", err)
+	}
+	c.Together = sharedGames(together, a.SummonerID, b.SummonerID)
+
+	return c
+}
+
+//compareFunc serves /compare?a=<summoner>&b=<summoner>. Both searches run at once, since neither depends on the other.
+func compareFunc(response http.ResponseWriter, request *http.Request) {
+
+	names := []string{request.FormValue("a"), request.FormValue("b")}
+	page := struct {
+		NameA, NameB string
+		Error        string
+		Comparison   *Comparison
+	}{NameA: names[0], NameB: names[1]}
+
+	if names[0] != "" && names[1] != "" {
+		outs := make([]*Output, 2)
+		errs := make([]error, 2)
+
+		var wg sync.WaitGroup
+		for i := range names {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				outs[i], errs[i] = summonerSearch(request.Context(), defaultRegion, names[i])
+			}(i)
+		}
+		wg.Wait()
+
+		for i, err := range errs {
+			if err == errNoSummoner {
+				page.Error = "No Summoner Found: " + names[i]
+			} else if err != nil {
+				logFrom(request.Context()).Warn("compare search failed", "err", err)
+				page.Error = "Could not look up " + names[i]
+			}
+		}
+
+		if page.Error == "" {
+			c := compare(request.Context(), outs[0], outs[1])
+			page.Comparison = &c
+		}
+	}
+
+	renderPage(request.Context(), response, "compare", page)
+
+}
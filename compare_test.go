@@ -0,0 +1,57 @@
+package main
+
+import "testing"
+
+//teamGame is a game between summoners 1, 2 and 3, where 1 and 2 are on the same team
+func teamGame(gameID int64, searched int) MatchInfo {
+
+	m := indexedMatch(gameID, gameID*100, map[int]string{1: "One", 2: "Two", 3: "Three"})
+	for i := range m.Stats.ParticipantIdentities {
+		id := &m.Stats.ParticipantIdentities[i]
+		id.ParticipantID = id.Player.SummonerID
+
+		var p Participant
+		p.ParticipantID = id.Player.SummonerID
+		p.TeamID = 100
+		if p.ParticipantID == 3 {
+			p.TeamID = 200
+		}
+		p.Stats.Win = p.TeamID == 100
+		m.Stats.Participants = append(m.Stats.Participants, p)
+	}
+	m.Stats.ParticipantID = searched
+	return m
+}
+
+func TestSharedGamesFromBothArchives(t *testing.T) {
+
+	oldDir := archiveDir
+	archiveDir = t.TempDir()
+	t.Cleanup(func() { archiveDir = oldDir })
+
+	//Game 1 was only archived when summoner 1 was searched, game 2 only when 3 was, and game 3 under both
+	archiveMatch("na", 10, teamGame(1, 1))
+	archiveMatch("na", 30, teamGame(2, 3))
+	archiveMatch("na", 10, teamGame(3, 1))
+	archiveMatch("na", 30, teamGame(3, 3))
+
+	matches, err := loadArchivesWith("na", 1, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shared := sharedGames(matches, 1, 3)
+	if len(shared) != 3 {
+		t.Fatalf("found %d shared games, want 3 with no repeats", len(shared))
+	}
+	for _, g := range shared {
+		if g.Together || !g.WinA {
+			t.Errorf("game %d: together %v, A won %v, want opponents where A won", g.GameID, g.Together, g.WinA)
+		}
+	}
+
+	//Summoner 2 was never searched, but played every game on summoner 1's team
+	shared = sharedGames(matches, 2, 1)
+	if len(shared) != 3 || !shared[0].Together {
+		t.Errorf("got %+v, want three games on the same team", shared)
+	}
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//matchFilter narrows down a summoner's archived matches. The zero value lets everything through.
+type matchFilter struct {
+	Champion string
+	Queue    int
+	Position string
+	From     time.Time
+	To       time.Time
+}
+
+//parseFilter reads a filter from champion, queue, position, from and to values, where the dates look like 2006-01-02.
+//get is request.FormValue for the web, or a flag lookup for the command line.
+func parseFilter(get func(string) string) (matchFilter, error) {
+
+	f := matchFilter{
+		Champion: get("champion"),
+		Position: get("position"),
+	}
+
+	var err error
+	if q := get("queue"); q != "" {
+		if f.Queue, err = strconv.Atoi(q); err != nil {
+			return f, fmt.Errorf("queue must be a number")
+		}
+	}
+	if from := get("from"); from != "" {
+		if f.From, err = time.Parse("2006-01-02", from); err != nil {
+			return f, fmt.Errorf("from must look like 2006-01-02")
+		}
+	}
+	if to := get("to"); to != "" {
+		if f.To, err = time.Parse("2006-01-02", to); err != nil {
+			return f, fmt.Errorf("to must look like 2006-01-02")
+		}
+		//"to" includes the whole of that day
+		f.To = f.To.AddDate(0, 0, 1)
+	}
+
+	return f, nil
+}
+
+func (f matchFilter) match(m MatchInfo) bool {
+
+	played := time.Unix(m.Timestamp/1000, 0)
+
+	switch {
+	case f.Champion != "" && !strings.EqualFold(f.Champion, m.Name):
+		return false
+	case f.Queue != 0 && f.Queue != m.Queue:
+		return false
+	case f.Position != "" && f.Position != m.Position():
+		return false
+	case !f.From.IsZero() && played.Before(f.From):
+		return false
+	case !f.To.IsZero() && !played.Before(f.To):
+		return false
+	}
+
+	return true
+}
+
+func (f matchFilter) apply(matches []MatchInfo) []MatchInfo {
+
+	var kept []MatchInfo
+	for _, m := range matches {
+		if f.match(m) {
+			kept = append(kept, m)
+		}
+	}
+
+	return kept
+}
+
+//csvColumns are the leading columns of every exported row. Every field of ParticipantStats and DerivedStats follows them.
+var csvColumns = []string{"gameId", "timestamp", "queue", "season", "gameDuration", "gameVersion",
+	"participantId", "summonerName", "accountId", "summonerId", "teamId", "championId", "position", "searched"}
+
+//statColumns flattens a struct's fields into CSV headers and values, using each field's JSON name when it has one
+func statColumns(v interface{}) (headers []string, values []string) {
+
+	rv := reflect.ValueOf(v)
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		name := strings.Split(rt.Field(i).Tag.Get("json"), ",")[0]
+		if name == "" {
+			name = rt.Field(i).Name
+		}
+		headers = append(headers, name)
+		values = append(values, fmt.Sprint(rv.Field(i).Interface()))
+	}
+
+	return headers, values
+}
+
+//writeCSV writes one row per player per game, for all ten players in each match.
+//"searched" marks the row belonging to the summoner whose archive this is.
+func writeCSV(w io.Writer, matches []MatchInfo) error {
+
+	out := csv.NewWriter(w)
+
+	statHeaders, _ := statColumns(ParticipantStats{})
+	derivedHeaders, _ := statColumns(DerivedStats{})
+	if err := out.Write(append(append(append([]string{}, csvColumns...), statHeaders...), derivedHeaders...)); err != nil {
+		return err
+	}
+
+	for _, m := range matches {
+		identities := make(map[int]ParticipantIdentity)
+		for _, id := range m.Stats.ParticipantIdentities {
+			identities[id.ParticipantID] = id
+		}
+
+		for _, p := range m.Stats.Participants {
+			player := identities[p.ParticipantID].Player
+
+			row := []string{
+				strconv.FormatInt(m.GameID, 10),
+				strconv.FormatInt(m.Timestamp, 10),
+				strconv.Itoa(m.Queue),
+				strconv.Itoa(m.Season),
+				strconv.Itoa(m.Stats.GameDuration),
+				m.Stats.GameVersion,
+				strconv.Itoa(p.ParticipantID),
+				player.SummonerName,
+				strconv.Itoa(player.AccountID),
+				strconv.Itoa(player.SummonerID),
+				strconv.Itoa(p.TeamID),
+				strconv.Itoa(p.ChampionID),
+				p.Position(),
+				strconv.FormatBool(p.ParticipantID == m.Stats.ParticipantID),
+			}
+
+			_, stats := statColumns(p.Stats)
+			_, derived := statColumns(p.Derived)
+			if err := out.Write(append(append(row, stats...), derived...)); err != nil {
+				return err
+			}
+		}
+	}
+
+	out.Flush()
+	return out.Error()
+}
+
+//writeNDJSON writes each match as one JSON document per line, derived stats included
+func writeNDJSON(w io.Writer, matches []MatchInfo) error {
+
+	enc := json.NewEncoder(w)
+	for _, m := range matches {
+		if err := enc.Encode(m); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+//writeExport writes matches in the given format, which is either "csv" or "ndjson"
+func writeExport(w io.Writer, format string, matches []MatchInfo) error {
+
+	switch format {
+	case "csv":
+		return writeCSV(w, matches)
+	case "ndjson":
+		return writeNDJSON(w, matches)
+	}
+
+	return fmt.Errorf("unknown export format %q, use csv or ndjson", format)
+}
+
+//exportFunc serves /export?account=<id>&format=csv|ndjson, plus any of the filter values, as a file download
+func exportFunc(response http.ResponseWriter, request *http.Request) {
+
+	accountID, err := strconv.Atoi(request.FormValue("account"))
+	if err != nil {
+		http.Error(response, "account must be a number", http.StatusBadRequest)
+		return
+	}
+
+	filter, err := parseFilter(request.FormValue)
+	if err != nil {
+		http.Error(response, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	format := request.FormValue("format")
+	contentType := map[string]string{"csv": "text/csv", "ndjson": "application/x-ndjson"}[format]
+	if contentType == "" {
+		http.Error(response, "format must be csv or ndjson", http.StatusBadRequest)
+		return
+	}
+
+	matches, err := loadArchive(defaultRegion, accountID)
+	if err != nil {
+		http.Error(response, "Could not read the match archive", http.StatusInternalServerError)
+		return
+	}
+
+	response.Header().Set("Content-Type", contentType)
+	response.Header().Set("Content-Disposition", "attachment; filename=\"ivern-"+strconv.Itoa(accountID)+"."+format+"\"")
+
+	if err := writeExport(response, format, filter.apply(matches)); err != nil {
+		logFrom(request.Context()).Warn("export failed", "err", err)
+	}
+
+}
+
+//exportCommand is `ivern export`, which writes the same files as /export to standard output
+func exportCommand(args []string) error {
+
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	region := flags.String("region", defaultRegion, "region the account plays on")
+	accountID := flags.Int("account", 0, "account ID whose archived matches to export")
+	format := flags.String("format", "csv", "csv or ndjson")
+	values := map[string]*string{
+		"champion": flags.String("champion", "", "only games on this champion"),
+		"queue":    flags.String("queue", "", "only games in this queue ID"),
+		"position": flags.String("position", "", "only games in this position (top, jungle, mid, adc, support)"),
+		"from":     flags.String("from", "", "only games on or after this date (2006-01-02)"),
+		"to":       flags.String("to", "", "only games on or before this date (2006-01-02)"),
+	}
+	flags.Parse(args)
+
+	if *accountID == 0 {
+		return fmt.Errorf("export needs -account")
+	}
+	if err := checkRegion(*region); err != nil {
+		return err
+	}
+
+	filter, err := parseFilter(func(name string) string { return *values[name] })
+	if err != nil {
+		return err
+	}
+
+	matches, err := loadArchive(*region, *accountID)
+	if err != nil {
+		return err
+	}
+
+	return writeExport(os.Stdout, *format, filter.apply(matches))
+}
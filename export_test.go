@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+	"time"
+)
+
+//values stands in for request.FormValue
+func values(v map[string]string) func(string) string {
+	return func(key string) string { return v[key] }
+}
+
+func TestParseFilter(t *testing.T) {
+
+	f, err := parseFilter(values(map[string]string{"champion": "Ahri", "queue": "420", "position": "mid", "from": "2018-03-01", "to": "2018-03-02"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := matchFilter{
+		Champion: "Ahri",
+		Queue:    420,
+		Position: "mid",
+		From:     time.Date(2018, 3, 1, 0, 0, 0, 0, time.UTC),
+		//"to" takes in the whole of its day
+		To: time.Date(2018, 3, 3, 0, 0, 0, 0, time.UTC),
+	}
+	if f != want {
+		t.Errorf("got %+v, want %+v", f, want)
+	}
+
+	for _, bad := range []map[string]string{{"queue": "solo"}, {"from": "03/01/2018"}, {"to": "yesterday"}} {
+		if _, err := parseFilter(values(bad)); err == nil {
+			t.Errorf("%v was accepted", bad)
+		}
+	}
+}
+
+func TestMatchFilter(t *testing.T) {
+
+	day := func(d int) int64 { return time.Date(2018, 3, d, 12, 0, 0, 0, time.UTC).Unix() * 1000 }
+	matches := []MatchInfo{
+		{GameID: 1, Name: "Ahri", Queue: 420, Lane: "MID", Timestamp: day(1)},
+		{GameID: 2, Name: "Annie", Queue: 420, Lane: "MID", Timestamp: day(2)},
+		{GameID: 3, Name: "Ahri", Queue: 440, Lane: "TOP", Timestamp: day(3)},
+	}
+
+	tests := []struct {
+		filter map[string]string
+		want   []int64
+	}{
+		{map[string]string{}, []int64{1, 2, 3}},
+		{map[string]string{"champion": "ahri"}, []int64{1, 3}},
+		{map[string]string{"queue": "420"}, []int64{1, 2}},
+		{map[string]string{"position": "top"}, []int64{3}},
+		{map[string]string{"from": "2018-03-02"}, []int64{2, 3}},
+		{map[string]string{"to": "2018-03-02"}, []int64{1, 2}},
+		{map[string]string{"champion": "Ahri", "queue": "420", "from": "2018-03-02"}, nil},
+	}
+
+	for _, tt := range tests {
+		f, err := parseFilter(values(tt.filter))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []int64
+		for _, m := range f.apply(matches) {
+			got = append(got, m.GameID)
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%v kept %v, want %v", tt.filter, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%v kept %v, want %v", tt.filter, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+
+	m := MatchInfo{GameID: 9, Timestamp: 1000, Queue: 420}
+	m.Stats.ParticipantID = 2
+	for id := 1; id <= 2; id++ {
+		var p Participant
+		p.ParticipantID = id
+		p.TeamID = 100 * id
+		p.Stats.Kills = id * 3
+		p.Stats.Win = id == 2
+		m.Stats.Participants = append(m.Stats.Participants, p)
+
+		var who ParticipantIdentity
+		who.ParticipantID = id
+		who.Player.SummonerName = []string{"", "Foo", "Bar, Baz"}[id]
+		m.Stats.ParticipantIdentities = append(m.Stats.ParticipantIdentities, who)
+	}
+
+	var buf bytes.Buffer
+	if err := writeCSV(&buf, []MatchInfo{m}); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("got %d rows, want a header and one per player", len(rows))
+	}
+
+	column := make(map[string]int)
+	for i, h := range rows[0] {
+		column[h] = i
+	}
+	for _, h := range []string{"gameId", "summonerName", "searched", "kills", "win"} {
+		if _, ok := column[h]; !ok {
+			t.Errorf("no %s column in %v", h, rows[0])
+		}
+	}
+
+	tests := []struct {
+		row                           int
+		name, team, kills, win, found string
+	}{
+		{1, "Foo", "100", "3", "false", "false"},
+		//Commas in names are quoted, so they don't spill into the next column
+		{2, "Bar, Baz", "200", "6", "true", "true"},
+	}
+	for _, tt := range tests {
+		row := rows[tt.row]
+		if len(row) != len(rows[0]) {
+			t.Errorf("row %d has %d columns, the header has %d", tt.row, len(row), len(rows[0]))
+			continue
+		}
+		got := []string{row[column["gameId"]], row[column["summonerName"]], row[column["teamId"]], row[column["kills"]], row[column["win"]], row[column["searched"]]}
+		want := []string{"9", tt.name, tt.team, tt.kills, tt.win, tt.found}
+		for i := range got {
+			if got[i] != want[i] {
+				t.Errorf("row %d = %v, want %v", tt.row, got, want)
+				break
+			}
+		}
+	}
+}
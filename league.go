@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"strings"
+)
+
+//LeagueEntry is a summoner's standing in one ranked queue, straight from the league positions endpoint
+type LeagueEntry struct {
+	QueueType    string `json:"queueType"`
+	Tier         string `json:"tier"`
+	Rank         string `json:"rank"`
+	LeaguePoints int    `json:"leaguePoints"`
+	Wins         int    `json:"wins"`
+	Losses       int    `json:"losses"`
+	HotStreak    bool   `json:"hotStreak"`
+	Veteran      bool   `json:"veteran"`
+	FreshBlood   bool   `json:"freshBlood"`
+	Inactive     bool   `json:"inactive"`
+
+	//MiniSeries is only there while the summoner is in their promotion games
+	MiniSeries *struct {
+		Target   int    `json:"target"`
+		Wins     int    `json:"wins"`
+		Losses   int    `json:"losses"`
+		Progress string `json:"progress"`
+	} `json:"miniSeries"`
+}
+
+//Ranked is the part of the profile about the summoner's current rank. A queue they haven't played this season is left nil.
+type Ranked struct {
+	Solo *LeagueEntry
+	Flex *LeagueEntry
+}
+
+//getLeagueEntries asks for every ranked queue the summoner has placed in this season
+func getLeagueEntries(ctx context.Context, region string, summonerID int) ([]LeagueEntry, error) {
+
+	var entries []LeagueEntry
+	if err := riotGet(ctx, leagueURL(region, summonerID), &entries); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+//leagueURL uses league v3, since that's the version that takes the numeric summoner IDs summoner v3 gives us
+func leagueURL(region string, summonerID int) string {
+	return riotURL(region, "/lol/league/v3/positions/by-summoner/"+strconv.Itoa(summonerID))
+}
+
+//ranked picks the solo and flex queues out of a summoner's league entries. Any other queue (like TFT) is ignored.
+func ranked(entries []LeagueEntry) Ranked {
+
+	var r Ranked
+	for i := range entries {
+		switch entries[i].QueueType {
+		case "RANKED_SOLO_5x5":
+			r.Solo = &entries[i]
+		case "RANKED_FLEX_SR":
+			r.Flex = &entries[i]
+		}
+	}
+
+	return r
+}
+
+func (e LeagueEntry) WinRate() float64 {
+	return percent(e.Wins, e.Wins+e.Losses)
+}
+
+//Division is how the rank reads in the client, like "Gold II". Master and above have no divisions.
+func (e LeagueEntry) Division() string {
+
+	tier := e.Tier
+	if tier != "" {
+		tier = tier[:1] + strings.ToLower(tier[1:])
+	}
+	switch e.Tier {
+	case "MASTER", "GRANDMASTER", "CHALLENGER":
+		return tier
+	}
+
+	return tier + " " + e.Rank
+}
+
+//Emblem is the ranked emblem image for the entry's tier
+func (e LeagueEntry) Emblem() string {
+	return "https://raw.communitydragon.org/latest/plugins/rcp-fe-lol-static-assets/global/default/images/ranked-emblem/emblem-" + strings.ToLower(e.Tier) + ".png"
+}
+
+//Series turns the promotion progress, like "WLN", into something easier to read on the page
+func (e LeagueEntry) Series() []string {
+
+	if e.MiniSeries == nil {
+		return nil
+	}
+
+	var games []string
+	for _, c := range e.MiniSeries.Progress {
+		switch c {
+		case 'W':
+			games = append(games, "Win")
+		case 'L':
+			games = append(games, "Loss")
+		default:
+			games = append(games, "-")
+		}
+	}
+
+	return games
+}
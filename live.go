@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+//CurrentGame is a game in progress, from the spectator endpoint
+type CurrentGame struct {
+	GameID            int64  `json:"gameId"`
+	GameMode          string `json:"gameMode"`
+	GameQueueConfigID int    `json:"gameQueueConfigId"`
+	GameStartTime     int64  `json:"gameStartTime"`
+	GameLength        int    `json:"gameLength"`
+	MapID             int    `json:"mapId"`
+	BannedChampions   []struct {
+		ChampionID int `json:"championId"`
+		TeamID     int `json:"teamId"`
+		PickTurn   int `json:"pickTurn"`
+	} `json:"bannedChampions"`
+	Participants []struct {
+		SummonerName string `json:"summonerName"`
+		SummonerID   int    `json:"summonerId"`
+		ChampionID   int    `json:"championId"`
+		TeamID       int    `json:"teamId"`
+		Spell1ID     int    `json:"spell1Id"`
+		Spell2ID     int    `json:"spell2Id"`
+		Bot          bool   `json:"bot"`
+		Perks        struct {
+			PerkIDs      []int `json:"perkIds"`
+			PerkStyle    int   `json:"perkStyle"`
+			PerkSubStyle int   `json:"perkSubStyle"`
+		} `json:"perks"`
+	} `json:"participants"`
+}
+
+//Minutes is how long the game has been going, counting from when the loading screen ended
+func (g CurrentGame) Minutes() int {
+	if g.GameStartTime == 0 {
+		return 0
+	}
+	return int(time.Since(time.Unix(g.GameStartTime/1000, 0)).Minutes())
+}
+
+//Rune trees and keystones, so the live game page can say more than a number
+var runeStyles = map[int]string{
+	8000: "Precision",
+	8100: "Domination",
+	8200: "Sorcery",
+	8300: "Inspiration",
+	8400: "Resolve",
+}
+
+var keystones = map[int]string{
+	8005: "Press the Attack",
+	8008: "Lethal Tempo",
+	8021: "Fleet Footwork",
+	8010: "Conqueror",
+	8112: "Electrocute",
+	8124: "Predator",
+	8128: "Dark Harvest",
+	9923: "Hail of Blades",
+	8214: "Summon Aery",
+	8229: "Arcane Comet",
+	8230: "Phase Rush",
+	8437: "Grasp of the Undying",
+	8439: "Aftershock",
+	8465: "Guardian",
+	8351: "Glacial Augment",
+	8360: "Unsealed Spellbook",
+	8369: "First Strike",
+}
+
+//getActiveGame asks whether a summoner is in a game right now. Riot answers "not in game" with a 404, which comes back as a nil game.
+//It's spectator v3, which takes and hands back the same numeric summoner IDs as summoner v3 and our archived matches.
+func getActiveGame(ctx context.Context, region string, summonerID int) (*CurrentGame, error) {
+
+	var game CurrentGame
+	err := riotGet(ctx, riotURL(region, "/lol/spectator/v3/active-games/by-summoner/"+strconv.Itoa(summonerID)), &game)
+	if isNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &game, nil
+}
+
+//LivePlayer is everything we show about one player on the live game page
+type LivePlayer struct {
+	SummonerName string
+	Champion     string
+	Spell1       string
+	Spell2       string
+	Keystone     string
+	Primary      string
+	Secondary    string
+	Bot          bool
+
+	//Rank is their solo queue standing, nil if they're unranked or we couldn't get it
+	Rank *LeagueEntry
+
+	//Recent is how they've done on this champion in whatever archived games they show up in, nil if none
+	Recent *ChampionStats
+}
+
+//LiveTeam is one side of the live game
+type LiveTeam struct {
+	TeamID  int
+	Players []LivePlayer
+	Bans    []string
+}
+
+//scoutGame fills in the live game page: names for everything, each player's rank, and their archived games on the champion
+//they locked in. Ranks are one API call per player, so they're all fetched at once instead of one after the other.
+func scoutGame(ctx context.Context, region string, game *CurrentGame) []LiveTeam {
+
+	//Only the accounts these ten players' games are stored under are read, not the whole archive
+	ids := make([]int, len(game.Participants))
+	for i, p := range game.Participants {
+		ids[i] = p.SummonerID
+	}
+	archive, err := loadArchivesWith(region, ids...)
+	if err != nil {
+		logFrom(ctx).Warn("archive access failed", "err", err)
+	}
+
+	teams := []LiveTeam{{TeamID: 100}, {TeamID: 200}}
+	team := func(id int) *LiveTeam {
+		if id == 200 {
+			return &teams[1]
+		}
+		return &teams[0]
+	}
+
+	for _, b := range game.BannedChampions {
+		//No ban shows up as champion -1
+		if b.ChampionID <= 0 {
+			continue
+		}
+		name, err := championName(b.ChampionID)
+		if err != nil {
+			name = "Champion " + strconv.Itoa(b.ChampionID)
+		}
+		t := team(b.TeamID)
+		t.Bans = append(t.Bans, name)
+	}
+
+	players := make([]LivePlayer, len(game.Participants))
+	var wg sync.WaitGroup
+
+	for i, p := range game.Participants {
+		name, err := championName(p.ChampionID)
+		if err != nil {
+			logFrom(ctx).Warn("champion name lookup failed", "err", err)
+			name = "Champion " + strconv.Itoa(p.ChampionID)
+		}
+
+		players[i] = LivePlayer{
+			SummonerName: p.SummonerName,
+			Champion:     name,
+			Spell1:       spellImage(p.Spell1ID),
+			Spell2:       spellImage(p.Spell2ID),
+			Primary:      runeStyles[p.Perks.PerkStyle],
+			Secondary:    runeStyles[p.Perks.PerkSubStyle],
+			Bot:          p.Bot,
+		}
+		if len(p.Perks.PerkIDs) > 0 {
+			players[i].Keystone = keystones[p.Perks.PerkIDs[0]]
+		}
+
+		//Every archived game this player shows up in on this champion, whoever's account it was stored under
+		var games []MatchInfo
+		for _, m := range archive {
+			if mine, ok := m.as(p.SummonerID); ok && mine.Champion == p.ChampionID {
+				games = append(games, mine)
+			}
+		}
+		if table := championBreakdown(games, "games", 1); len(table) > 0 {
+			players[i].Recent = &table[0]
+		}
+
+		if p.Bot {
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, summonerID int) {
+			defer wg.Done()
+			entries, err := getLeagueEntries(ctx, region, summonerID)
+			if err != nil {
+				logFrom(ctx).Warn("league entries lookup failed", "err", err)
+				return
+			}
+			players[i].Rank = ranked(entries).Solo
+		}(i, p.SummonerID)
+	}
+
+	wg.Wait()
+
+	for i, p := range game.Participants {
+		t := team(p.TeamID)
+		t.Players = append(t.Players, players[i])
+	}
+
+	return teams
+}
+
+//liveFunc serves /live?name=<summoner>, the scouting view of the game they're in right now
+func liveFunc(response http.ResponseWriter, request *http.Request) {
+
+	profile, err := getSummoner(request.Context(), defaultRegion, request.FormValue("name"))
+	if err != nil || profile.ID == 0 {
+		http.Error(response, "No Summoner Found", http.StatusNotFound)
+		return
+	}
+
+	game, err := getActiveGame(request.Context(), defaultRegion, profile.ID)
+	if err != nil {
+		logFrom(request.Context()).Warn("active game lookup failed", "err", err)
+		http.Error(response, "Could not check for a live game", http.StatusBadGateway)
+		return
+	}
+
+	page := struct {
+		SummonerName string
+		Game         *CurrentGame
+		Teams        []LiveTeam
+	}{SummonerName: profile.Name, Game: game}
+
+	if game != nil {
+		page.Teams = scoutGame(request.Context(), defaultRegion, game)
+	}
+
+	renderPage(request.Context(), response, "live", page)
+
+}
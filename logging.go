@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//setupLogging makes slog's default logger write one JSON object per line to standard error, for the log pipeline.
+//IVERN_LOG_LEVEL picks the lowest level written (debug, info, warn or error, info by default)
+//and IVERN_LOG_FORMAT=text switches to key=value lines, which are easier to read in a terminal.
+func setupLogging() {
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(os.Getenv("IVERN_LOG_LEVEL"))); err != nil {
+		level = slog.LevelInfo
+	}
+	options := &slog.HandlerOptions{Level: level}
+
+	var handler slog.Handler = slog.NewJSONHandler(os.Stderr, options)
+	if os.Getenv("IVERN_LOG_FORMAT") == "text" {
+		handler = slog.NewTextHandler(os.Stderr, options)
+	}
+
+	slog.SetDefault(slog.New(handler))
+}
+
+type loggerKey struct{}
+
+//logFrom hands back the logger for whatever ctx belongs to, so everything logged during one request carries its request ID.
+//Outside of a request it's just the default logger.
+func logFrom(ctx context.Context) *slog.Logger {
+	if l, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return l
+	}
+	return slog.Default()
+}
+
+//newRequestID is 8 random bytes in hex, plenty to tell requests apart in the logs
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+//statusRecorder remembers the status a handler answered with, so it can be logged afterwards
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+//withRequestID gives every incoming request an ID, which goes into its context's logger and back out in the X-Request-ID header.
+//A request that already has an X-Request-ID (say, from a proxy in front of us) keeps it. Only the path is logged,
+//since the query string is where summoner names are.
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+
+		id := request.Header.Get("X-Request-ID")
+		if id == "" {
+			id = newRequestID()
+		}
+		response.Header().Set("X-Request-ID", id)
+
+		logger := slog.Default().With("request_id", id)
+		request = request.WithContext(context.WithValue(request.Context(), loggerKey{}, logger))
+
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: response, status: http.StatusOK}
+		next.ServeHTTP(recorder, request)
+
+		logger.Info("request",
+			"method", request.Method,
+			"path", request.URL.Path,
+			"status", recorder.status,
+			"latency_ms", time.Since(start).Milliseconds(),
+		)
+	})
+}
+
+//rateLimitHeadroom is how many more calls fit in the tightest window of a Riot rate limit.
+//limits looks like "20:1,100:120" (20 calls per second, 100 per 2 minutes) and counts like "3:1,41:120".
+//It's -1 if Riot didn't send the headers.
+func rateLimitHeadroom(limits string, counts string) int {
+
+	used := make(map[string]int)
+	for _, c := range strings.Split(counts, ",") {
+		if parts := strings.SplitN(c, ":", 2); len(parts) == 2 {
+			used[parts[1]], _ = strconv.Atoi(parts[0])
+		}
+	}
+
+	headroom := -1
+	for _, l := range strings.Split(limits, ",") {
+		parts := strings.SplitN(l, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		limit, err := strconv.Atoi(parts[0])
+		if err != nil {
+			continue
+		}
+		if left := limit - used[parts[1]]; headroom < 0 || left < headroom {
+			headroom = left
+		}
+	}
+
+	return headroom
+}
+
+//withoutURL strips the address out of a failed request's error, since the address has our API key in it
+func withoutURL(err error) error {
+	var e *url.Error
+	if errors.As(err, &e) {
+		return fmt.Errorf("%s: %w", e.Op, e.Err)
+	}
+	return err
+}
+
+//observeUpstream logs and counts one call to Riot. The URL itself is never logged, since it has our API key and often a summoner name in it.
+func observeUpstream(ctx context.Context, address string, resp *http.Response, err error, latency time.Duration) {
+
+	endpoint := cacheRuleFor(address).Name
+	attrs := []any{
+		"endpoint", endpoint,
+		"latency_ms", latency.Milliseconds(),
+	}
+
+	if err != nil {
+		recordUpstream(endpoint, "error", -1, -1)
+		logFrom(ctx).Warn("riot call failed", append(attrs, "err", err)...)
+		return
+	}
+
+	appHeadroom := rateLimitHeadroom(resp.Header.Get("X-App-Rate-Limit"), resp.Header.Get("X-App-Rate-Limit-Count"))
+	methodHeadroom := rateLimitHeadroom(resp.Header.Get("X-Method-Rate-Limit"), resp.Header.Get("X-Method-Rate-Limit-Count"))
+	recordUpstream(endpoint, strconv.Itoa(resp.StatusCode), appHeadroom, methodHeadroom)
+	noteKeyStatus(resp.StatusCode)
+
+	attrs = append(attrs,
+		"status", resp.StatusCode,
+		"app_headroom", appHeadroom,
+		"method_headroom", methodHeadroom,
+	)
+	if retry := resp.Header.Get("Retry-After"); retry != "" {
+		attrs = append(attrs, "retry_after", retry)
+	}
+
+	level := slog.LevelInfo
+	if resp.StatusCode >= 400 && resp.StatusCode != http.StatusNotFound {
+		level = slog.LevelWarn
+	}
+	logFrom(ctx).Log(ctx, level, "riot call", attrs...)
+}
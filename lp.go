@@ -0,0 +1,212 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//RankSnapshot is a summoner's solo queue standing at one moment, saved every time we sync them
+type RankSnapshot struct {
+	Time   int64  `json:"time"`
+	Tier   string `json:"tier"`
+	Rank   string `json:"rank"`
+	LP     int    `json:"lp"`
+	Wins   int    `json:"wins"`
+	Losses int    `json:"losses"`
+}
+
+//The ranked solo queue ID, as it shows up in the matchlist
+const soloQueue = 420
+
+var tierOrder = []string{"IRON", "BRONZE", "SILVER", "GOLD", "PLATINUM", "DIAMOND", "MASTER", "GRANDMASTER", "CHALLENGER"}
+var divisionOrder = []string{"IV", "III", "II", "I"}
+
+//Snapshots are kept per account in archive/lp/<accountID>.json, outside the account's match folder
+func snapshotPath(region string, accountID int) string {
+	return filepath.Join(regionArchive(region), "lp", strconv.Itoa(accountID)+".json")
+}
+
+//loadSnapshots reads an account's rank history, oldest first
+func loadSnapshots(region string, accountID int) ([]RankSnapshot, error) {
+
+	var snapshots []RankSnapshot
+
+	data, err := os.ReadFile(snapshotPath(region, accountID))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(data, &snapshots)
+	return snapshots, err
+}
+
+//recordSnapshot adds the summoner's current solo queue rank to their history.
+//Nothing is saved if they aren't ranked, or if nothing changed since the last snapshot, so repeat searches don't pad the graph.
+func recordSnapshot(region string, accountID int, r Ranked, now time.Time) error {
+
+	if r.Solo == nil {
+		return nil
+	}
+
+	snapshots, err := loadSnapshots(region, accountID)
+	if err != nil {
+		return err
+	}
+
+	s := RankSnapshot{
+		Time:   now.UnixNano() / int64(time.Millisecond),
+		Tier:   r.Solo.Tier,
+		Rank:   r.Solo.Rank,
+		LP:     r.Solo.LeaguePoints,
+		Wins:   r.Solo.Wins,
+		Losses: r.Solo.Losses,
+	}
+
+	if n := len(snapshots); n > 0 {
+		last := snapshots[n-1]
+		if last.Tier == s.Tier && last.Rank == s.Rank && last.LP == s.LP && last.Wins == s.Wins && last.Losses == s.Losses {
+			return nil
+		}
+	}
+
+	snapshots = append(snapshots, s)
+
+	if err := os.MkdirAll(filepath.Dir(snapshotPath(region, accountID)), 0755); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(snapshots)
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(snapshotPath(region, accountID), data)
+}
+
+//totalLP puts every rank on one scale, 100 LP per division and 400 per tier, so that promotions and demotions
+//still show up as the right number of LP gained or lost. Master and above share one ladder with no divisions.
+func (s RankSnapshot) totalLP() int {
+
+	tier := 0
+	for i, t := range tierOrder {
+		if t == s.Tier {
+			tier = i
+		}
+	}
+
+	if tier >= 6 {
+		return 6*400 + s.LP
+	}
+
+	division := 0
+	for i, d := range divisionOrder {
+		if d == s.Rank {
+			division = i
+		}
+	}
+
+	return tier*400 + division*100 + s.LP
+}
+
+func (s RankSnapshot) String() string {
+	return LeagueEntry{Tier: s.Tier, Rank: s.Rank}.Division() + " " + strconv.Itoa(s.LP) + " LP"
+}
+
+//LPHistory is what the profile shows about rank over time
+type LPHistory struct {
+	Chart template.HTML
+
+	//Changes maps a game ID to the LP it was worth, for the games where we could tell
+	Changes map[int64]int
+}
+
+//lpHistory lines the snapshots up with the archived matches. When exactly one solo queue game was played between two
+//snapshots, and exactly one archived solo queue game falls between them, the whole LP difference belongs to that game.
+func lpHistory(snapshots []RankSnapshot, matches []MatchInfo) LPHistory {
+
+	history := LPHistory{Changes: make(map[int64]int)}
+
+	for i := 1; i < len(snapshots); i++ {
+		before, after := snapshots[i-1], snapshots[i]
+
+		if (after.Wins+after.Losses)-(before.Wins+before.Losses) != 1 {
+			continue
+		}
+
+		var between []MatchInfo
+		for _, m := range matches {
+			if m.Queue == soloQueue && m.Timestamp > before.Time && m.Timestamp <= after.Time {
+				between = append(between, m)
+			}
+		}
+
+		if len(between) == 1 {
+			history.Changes[between[0].GameID] = after.totalLP() - before.totalLP()
+		}
+	}
+
+	history.Chart = lpChart(snapshots)
+
+	return history
+}
+
+//lpChart draws the snapshots as a line, one point per snapshot, with the rank at each point in its tooltip
+func lpChart(snapshots []RankSnapshot) template.HTML {
+
+	const width, height, pad = 600, 160, 20
+
+	if len(snapshots) < 2 {
+		return ""
+	}
+
+	low, high := snapshots[0], snapshots[0]
+	for _, s := range snapshots {
+		if s.totalLP() < low.totalLP() {
+			low = s
+		}
+		if s.totalLP() > high.totalLP() {
+			high = s
+		}
+	}
+
+	//Leave at least one division of room, so a flat line still sits in the middle
+	span := float64(high.totalLP() - low.totalLP())
+	if span < 100 {
+		span = 100
+	}
+	mid := float64(high.totalLP()+low.totalLP()) / 2
+
+	x := func(i int) float64 { return pad + float64(i)*float64(width-2*pad)/float64(len(snapshots)-1) }
+	y := func(lp int) float64 { return float64(height)/2 - (float64(lp)-mid)/span*(float64(height)-2*pad) }
+
+	var svg strings.Builder
+	fmt.Fprintf(&svg, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" font-family="sans-serif" font-size="10">`, width, height)
+	fmt.Fprintf(&svg, `<rect width="%d" height="%d" fill="#2B2B2B"/>`, width, height)
+	fmt.Fprintf(&svg, `<text x="2" y="12" fill="#FFFFFF">%s</text>`, template.HTMLEscapeString(high.String()))
+	fmt.Fprintf(&svg, `<text x="2" y="%d" fill="#FFFFFF">%s</text>`, height-4, template.HTMLEscapeString(low.String()))
+
+	points := make([]string, len(snapshots))
+	for i, s := range snapshots {
+		points[i] = fmt.Sprintf("%.1f,%.1f", x(i), y(s.totalLP()))
+	}
+	fmt.Fprintf(&svg, `<polyline points="%s" fill="none" stroke="LightGreen" stroke-width="2"/>`, strings.Join(points, " "))
+
+	for i, s := range snapshots {
+		fmt.Fprintf(&svg, `<circle cx="%.1f" cy="%.1f" r="3" fill="LightGreen"><title>%s, %s</title></circle>`,
+			x(i), y(s.totalLP()), template.HTMLEscapeString(s.String()), time.Unix(s.Time/1000, 0).Format("Jan 2 15:04"))
+	}
+
+	svg.WriteString(`</svg>`)
+
+	//Tier names come from Riot, so they're escaped above. Everything else is a number or a date.
+	return template.HTML(svg.String())
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTotalLP(t *testing.T) {
+
+	tests := []struct {
+		tier, rank string
+		lp         int
+		want       int
+	}{
+		{"IRON", "IV", 0, 0},
+		{"IRON", "I", 50, 350},
+		{"BRONZE", "IV", 0, 400},
+		{"GOLD", "II", 75, 3*400 + 2*100 + 75},
+		{"DIAMOND", "I", 100, 5*400 + 3*100 + 100},
+		//Master and up is one ladder, so the rank (always "I") doesn't add anything
+		{"MASTER", "I", 0, 2400},
+		{"GRANDMASTER", "I", 350, 2750},
+		{"CHALLENGER", "I", 900, 3300},
+	}
+
+	for _, tt := range tests {
+		s := RankSnapshot{Tier: tt.tier, Rank: tt.rank, LP: tt.lp}
+		if got := s.totalLP(); got != tt.want {
+			t.Errorf("%s %s %d LP = %d, want %d", tt.tier, tt.rank, tt.lp, got, tt.want)
+		}
+	}
+}
+
+func TestLPHistory(t *testing.T) {
+
+	snap := func(minute int64, tier, rank string, lp, wins, losses int) RankSnapshot {
+		return RankSnapshot{Time: minute * 60000, Tier: tier, Rank: rank, LP: lp, Wins: wins, Losses: losses}
+	}
+	game := func(id int64, minute int64, queue int) MatchInfo {
+		return MatchInfo{GameID: id, Timestamp: minute * 60000, Queue: queue}
+	}
+
+	tests := []struct {
+		name      string
+		snapshots []RankSnapshot
+		matches   []MatchInfo
+		want      map[int64]int
+	}{
+		{
+			name:      "one win",
+			snapshots: []RankSnapshot{snap(0, "GOLD", "II", 40, 10, 10), snap(60, "GOLD", "II", 58, 11, 10)},
+			matches:   []MatchInfo{game(1, 30, soloQueue)},
+			want:      map[int64]int{1: 18},
+		},
+		{
+			name:      "promotion to the next division",
+			snapshots: []RankSnapshot{snap(0, "GOLD", "II", 90, 10, 10), snap(60, "GOLD", "I", 5, 11, 10)},
+			matches:   []MatchInfo{game(1, 30, soloQueue)},
+			want:      map[int64]int{1: 15},
+		},
+		{
+			name:      "demotion to the tier below",
+			snapshots: []RankSnapshot{snap(0, "PLATINUM", "IV", 0, 10, 10), snap(60, "GOLD", "I", 75, 10, 11)},
+			matches:   []MatchInfo{game(1, 30, soloQueue)},
+			want:      map[int64]int{1: -25},
+		},
+		{
+			name:      "promotion into master",
+			snapshots: []RankSnapshot{snap(0, "DIAMOND", "I", 100, 10, 10), snap(60, "MASTER", "I", 0, 11, 10)},
+			matches:   []MatchInfo{game(1, 30, soloQueue)},
+			want:      map[int64]int{1: 0},
+		},
+		{
+			name:      "master ladder",
+			snapshots: []RankSnapshot{snap(0, "MASTER", "I", 120, 10, 10), snap(60, "MASTER", "I", 105, 10, 11)},
+			matches:   []MatchInfo{game(1, 30, soloQueue)},
+			want:      map[int64]int{1: -15},
+		},
+		{
+			name:      "more than one game between snapshots",
+			snapshots: []RankSnapshot{snap(0, "GOLD", "II", 40, 10, 10), snap(60, "GOLD", "II", 20, 11, 11)},
+			matches:   []MatchInfo{game(1, 20, soloQueue), game(2, 40, soloQueue)},
+			want:      map[int64]int{},
+		},
+		{
+			//The rank says one game, but two archived games fall in between, so we can't tell which it was
+			name:      "two archived games for one played",
+			snapshots: []RankSnapshot{snap(0, "GOLD", "II", 40, 10, 10), snap(60, "GOLD", "II", 58, 11, 10)},
+			matches:   []MatchInfo{game(1, 20, soloQueue), game(2, 40, soloQueue)},
+			want:      map[int64]int{},
+		},
+		{
+			name:      "only solo queue games count",
+			snapshots: []RankSnapshot{snap(0, "GOLD", "II", 40, 10, 10), snap(60, "GOLD", "II", 58, 11, 10)},
+			matches:   []MatchInfo{game(1, 20, 440), game(2, 40, soloQueue)},
+			want:      map[int64]int{2: 18},
+		},
+		{
+			//The game that was played never got archived, so the archived game after both snapshots isn't blamed for it
+			name:      "archived game outside the snapshots",
+			snapshots: []RankSnapshot{snap(0, "GOLD", "II", 40, 10, 10), snap(60, "GOLD", "II", 58, 11, 10)},
+			matches:   []MatchInfo{game(1, 90, soloQueue)},
+			want:      map[int64]int{},
+		},
+		{
+			name: "each gap on its own",
+			snapshots: []RankSnapshot{
+				snap(0, "GOLD", "II", 40, 10, 10),
+				snap(60, "GOLD", "II", 58, 11, 10),
+				snap(120, "GOLD", "II", 20, 12, 12),
+				snap(180, "GOLD", "II", 2, 12, 13),
+			},
+			matches: []MatchInfo{game(1, 30, soloQueue), game(2, 150, soloQueue)},
+			want:    map[int64]int{1: 18, 2: -18},
+		},
+	}
+
+	for _, tt := range tests {
+		got := lpHistory(tt.snapshots, tt.matches).Changes
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+			continue
+		}
+		for id, lp := range tt.want {
+			if got[id] != lp {
+				t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestLPChart(t *testing.T) {
+
+	one := []RankSnapshot{{Time: time.Now().Unix() * 1000, Tier: "GOLD", Rank: "II", LP: 40}}
+	if chart := lpHistory(one, nil).Chart; chart != "" {
+		t.Errorf("one snapshot drew %q, want nothing", chart)
+	}
+
+	two := append(one, RankSnapshot{Time: one[0].Time + 60000, Tier: "GOLD", Rank: "II", LP: 58})
+	if chart := lpHistory(two, nil).Chart; chart == "" {
+		t.Error("two snapshots didn't draw a line")
+	}
+}
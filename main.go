@@ -1,164 +1,251 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"html/template"
-	"log"
+	"io"
+	"math/rand"
 	"net/http"
+	"net/url"
+	"os"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
 //types allow for custom variable types, as well as custom structures to the variable
 type Output struct {
-	SummonerName              string
-	ProfileIconID             int
-	AccountID                 int
-	HighestAchievedSeasonTier string
+	SummonerName  string
+	ProfileIconID int
+	AccountID     int
+	SummonerID    int
+	Region        string
 
-	Match []struct {
-		Name       string
-		Lane       string `json:"lane"`
-		GameID     int64  `json:"gameId"`
-		Champion   int    `json:"champion"`
-		PlatformID string `json:"platformId"`
-		Timestamp  int64  `json:"timestamp"`
-		Queue      int    `json:"queue"`
-		Role       string `json:"role"`
-		Season     int    `json:"season"`
-		Image      string
-		Stats      struct {
-			ParticipantIdentities []struct {
-				Player struct {
-					CurrentPlatformID string `json:"currentPlatformId"`
-					SummonerName      string `json:"summonerName"`
-					MatchHistoryURI   string `json:"matchHistoryUri"`
-					PlatformID        string `json:"platformId"`
-					CurrentAccountID  int    `json:"currentAccountId"`
-					ProfileIcon       int    `json:"profileIcon"`
-					SummonerID        int    `json:"summonerId"`
-					AccountID         int    `json:"accountId"`
-				} `json:"player"`
-				ParticipantID int `json:"participantId"`
-			} `json:"participantIdentities"`
-			ParticipantID int
-			GameVersion   string `json:"gameVersion"`
-			PlatformID    string `json:"platformId"`
-			GameMode      string `json:"gameMode"`
-			MapID         int    `json:"mapId"`
-			GameType      string `json:"gameType"`
-			Teams         []struct {
-				FirstDragon bool `json:"firstDragon"`
-				Bans        []struct {
-					PickTurn   int `json:"pickTurn"`
-					ChampionID int `json:"championId"`
-				} `json:"bans"`
-				FirstInhibitor       bool   `json:"firstInhibitor"`
-				Win                  string `json:"win"`
-				FirstRiftHerald      bool   `json:"firstRiftHerald"`
-				FirstBaron           bool   `json:"firstBaron"`
-				BaronKills           int    `json:"baronKills"`
-				RiftHeraldKills      int    `json:"riftHeraldKills"`
-				FirstBlood           bool   `json:"firstBlood"`
-				TeamID               int    `json:"teamId"`
-				FirstTower           bool   `json:"firstTower"`
-				VilemawKills         int    `json:"vilemawKills"`
-				InhibitorKills       int    `json:"inhibitorKills"`
-				TowerKills           int    `json:"towerKills"`
-				DominionVictoryScore int    `json:"dominionVictoryScore"`
-				DragonKills          int    `json:"dragonKills"`
-			} `json:"teams"`
-			Participants []struct {
-				Stats struct {
-					HighestStreak                   string
-					Item1                           int  `json:"item1"`
-					TotalPlayerScore                int  `json:"totalPlayerScore"`
-					VisionScore                     int  `json:"visionScore"`
-					UnrealKills                     int  `json:"unrealKills"`
-					Win                             bool `json:"win"`
-					ObjectivePlayerScore            int  `json:"objectivePlayerScore"`
-					LargestCriticalStrike           int  `json:"largestCriticalStrike"`
-					TotalDamageDealt                int  `json:"totalDamageDealt"`
-					MagicDamageDealtToChampions     int  `json:"magicDamageDealtToChampions"`
-					LargestMultiKill                int  `json:"largestMultiKill"`
-					LargestKillingSpree             int  `json:"largestKillingSpree"`
-					QuadraKills                     int  `json:"quadraKills"`
-					TotalTimeCrowdControlDealt      int  `json:"totalTimeCrowdControlDealt"`
-					MagicalDamageTaken              int  `json:"magicalDamageTaken"`
-					LongestTimeSpentLiving          int  `json:"longestTimeSpentLiving"`
-					NeutralMinionsKilledEnemyJungle int  `json:"neutralMinionsKilledEnemyJungle"`
-					FirstTowerAssist                bool `json:"firstTowerAssist"`
-					NeutralMinionsKilledTeamJungle  int  `json:"neutralMinionsKilledTeamJungle"`
-					GoldEarned                      int  `json:"goldEarned"`
-					Item2                           int  `json:"item2"`
-					Item3                           int  `json:"item3"`
-					Item0                           int  `json:"item0"`
-					Deaths                          int  `json:"deaths"`
-					Item6                           int  `json:"item6"`
-					WardsPlaced                     int  `json:"wardsPlaced"`
-					Item4                           int  `json:"item4"`
-					Item5                           int  `json:"item5"`
-					TurretKills                     int  `json:"turretKills"`
-					TripleKills                     int  `json:"tripleKills"`
-					DamageSelfMitigated             int  `json:"damageSelfMitigated"`
-					GoldSpent                       int  `json:"goldSpent"`
-					MagicDamageDealt                int  `json:"magicDamageDealt"`
-					Kills                           int  `json:"kills"`
-					DoubleKills                     int  `json:"doubleKills"`
-					FirstInhibitorKill              bool `json:"firstInhibitorKill"`
-					TrueDamageTaken                 int  `json:"trueDamageTaken"`
-					FirstBloodAssist                bool `json:"firstBloodAssist"`
-					FirstBloodKill                  bool `json:"firstBloodKill"`
-					Assists                         int  `json:"assists"`
-					TotalScoreRank                  int  `json:"totalScoreRank"`
-					NeutralMinionsKilled            int  `json:"neutralMinionsKilled"`
-					CombatPlayerScore               int  `json:"combatPlayerScore"`
-					VisionWardsBoughtInGame         int  `json:"visionWardsBoughtInGame"`
-					DamageDealtToTurrets            int  `json:"damageDealtToTurrets"`
-					PhysicalDamageDealtToChampions  int  `json:"physicalDamageDealtToChampions"`
-					PentaKills                      int  `json:"pentaKills"`
-					TrueDamageDealt                 int  `json:"trueDamageDealt"`
-					TrueDamageDealtToChampions      int  `json:"trueDamageDealtToChampions"`
-					ChampLevel                      int  `json:"champLevel"`
-					ParticipantID                   int  `json:"participantId"`
-					FirstInhibitorAssist            bool `json:"firstInhibitorAssist"`
-					WardsKilled                     int  `json:"wardsKilled"`
-					FirstTowerKill                  bool `json:"firstTowerKill"`
-					TotalHeal                       int  `json:"totalHeal"`
-					TotalMinionsKilled              int  `json:"totalMinionsKilled"`
-					PhysicalDamageDealt             int  `json:"physicalDamageDealt"`
-					DamageDealtToObjectives         int  `json:"damageDealtToObjectives"`
-					SightWardsBoughtInGame          int  `json:"sightWardsBoughtInGame"`
-					TotalDamageDealtToChampions     int  `json:"totalDamageDealtToChampions"`
-					TotalUnitsHealed                int  `json:"totalUnitsHealed"`
-					InhibitorKills                  int  `json:"inhibitorKills"`
-					TotalDamageTaken                int  `json:"totalDamageTaken"`
-					KillingSprees                   int  `json:"killingSprees"`
-					TimeCCingOthers                 int  `json:"timeCCingOthers"`
-					PhysicalDamageTaken             int  `json:"physicalDamageTaken"`
-				} `json:"stats"`
-				Spell1ID      int `json:"spell1Id"`
-				ParticipantID int `json:"participantId"`
-				Runes         []struct {
-					RuneID int `json:"runeId"`
-					Rank   int `json:"rank"`
-				} `json:"runes"`
-				HighestAchievedSeasonTier string `json:"highestAchievedSeasonTier"`
-				Masteries                 []struct {
-					MasteryID int `json:"masteryId"`
-					Rank      int `json:"rank"`
-				} `json:"masteries"`
-				Spell2ID   int `json:"spell2Id"`
-				TeamID     int `json:"teamId"`
-				ChampionID int `json:"championId"`
-				Spell1Full string
-				Spell2Full string
-			} `json:"participants"`
-
-			GameDuration int   `json:"gameDuration"`
-			GameCreation int64 `json:"gameCreation"`
+	//Ranked is the summoner's current rank in each queue, from the league endpoint
+	Ranked Ranked
+
+	//LiveGame is the game the summoner is playing right now, or nil
+	LiveGame *CurrentGame
+
+	//Stale is set when Riot wasn't answering and everything came from the archive and cache instead.
+	//LastUpdated is when that stored data was fetched, or zero if we can't tell.
+	Stale       bool
+	LastUpdated time.Time
+
+	Match []MatchInfo `json:"matches"`
+
+	//Champions is built from every match in the archive, not just the few we fetched for this search
+	Champions ChampionTable
+	Positions PositionBreakdown
+	LP        LPHistory
+}
+
+//MatchInfo is a single entry from the matchlist, along with the full match details stored in Stats
+type MatchInfo struct {
+	Name       string
+	Lane       string `json:"lane"`
+	GameID     int64  `json:"gameId"`
+	Champion   int    `json:"champion"`
+	PlatformID string `json:"platformId"`
+	Timestamp  int64  `json:"timestamp"`
+	Queue      int    `json:"queue"`
+	Role       string `json:"role"`
+	Season     int    `json:"season"`
+	Image      string
+	Stats      MatchStats
+
+	//Unmatched is set when the searched account isn't one of the match's participants, so there are no stats of "ours" to show
+	Unmatched bool `json:"unmatched"`
+}
+
+//MatchStats holds everything the match endpoint gives us about a single game
+type MatchStats struct {
+	ParticipantIdentities []ParticipantIdentity `json:"participantIdentities"`
+	ParticipantID         int
+	GameVersion           string        `json:"gameVersion"`
+	PlatformID            string        `json:"platformId"`
+	GameMode              string        `json:"gameMode"`
+	MapID                 int           `json:"mapId"`
+	GameType              string        `json:"gameType"`
+	Teams                 []Team        `json:"teams"`
+	Participants          []Participant `json:"participants"`
+
+	GameDuration int   `json:"gameDuration"`
+	GameCreation int64 `json:"gameCreation"`
+}
+
+//player finds the searched summoner's own participant entry in a match, using the ParticipantID found by getMatchInfo.
+//Unmatched matches have no ParticipantID, so they're never counted towards anyone's stats.
+func (m MatchInfo) player() (Participant, bool) {
+	for _, p := range m.Stats.Participants {
+		if p.ParticipantID == m.Stats.ParticipantID {
+			return p, true
 		}
-	} `json:"matches"`
+	}
+	return Participant{}, false
+}
+
+//as re-points a match at a different player in it, found by summoner ID, so that anything written for "our" player
+//(like championBreakdown) works for anyone in an archived match. ok is false if they didn't play in it.
+func (m MatchInfo) as(summonerID int) (MatchInfo, bool) {
+
+	for _, id := range m.Stats.ParticipantIdentities {
+		if id.Player.SummonerID != summonerID {
+			continue
+		}
+		for _, p := range m.Stats.Participants {
+			if p.ParticipantID == id.ParticipantID {
+				if p.ChampionID != m.Champion {
+					m.Name, _ = championName(p.ChampionID)
+				}
+				m.Stats.ParticipantID = p.ParticipantID
+				m.Champion = p.ChampionID
+				m.Lane = p.Timeline.Lane
+				m.Role = p.Timeline.Role
+				m.Unmatched = false
+				return m, true
+			}
+		}
+	}
+
+	return m, false
+}
+
+type ParticipantIdentity struct {
+	Player struct {
+		CurrentPlatformID string `json:"currentPlatformId"`
+		SummonerName      string `json:"summonerName"`
+		MatchHistoryURI   string `json:"matchHistoryUri"`
+		PlatformID        string `json:"platformId"`
+		CurrentAccountID  int    `json:"currentAccountId"`
+		ProfileIcon       int    `json:"profileIcon"`
+		SummonerID        int    `json:"summonerId"`
+		AccountID         int    `json:"accountId"`
+	} `json:"player"`
+	ParticipantID int `json:"participantId"`
+}
+
+type Team struct {
+	FirstDragon bool `json:"firstDragon"`
+	Bans        []struct {
+		PickTurn   int `json:"pickTurn"`
+		ChampionID int `json:"championId"`
+	} `json:"bans"`
+	FirstInhibitor       bool   `json:"firstInhibitor"`
+	Win                  string `json:"win"`
+	FirstRiftHerald      bool   `json:"firstRiftHerald"`
+	FirstBaron           bool   `json:"firstBaron"`
+	BaronKills           int    `json:"baronKills"`
+	RiftHeraldKills      int    `json:"riftHeraldKills"`
+	FirstBlood           bool   `json:"firstBlood"`
+	TeamID               int    `json:"teamId"`
+	FirstTower           bool   `json:"firstTower"`
+	VilemawKills         int    `json:"vilemawKills"`
+	InhibitorKills       int    `json:"inhibitorKills"`
+	TowerKills           int    `json:"towerKills"`
+	DominionVictoryScore int    `json:"dominionVictoryScore"`
+	DragonKills          int    `json:"dragonKills"`
+}
+
+type Participant struct {
+	Stats         ParticipantStats `json:"stats"`
+	Spell1ID      int              `json:"spell1Id"`
+	ParticipantID int              `json:"participantId"`
+	Runes         []struct {
+		RuneID int `json:"runeId"`
+		Rank   int `json:"rank"`
+	} `json:"runes"`
+	HighestAchievedSeasonTier string `json:"highestAchievedSeasonTier"`
+	Masteries                 []struct {
+		MasteryID int `json:"masteryId"`
+		Rank      int `json:"rank"`
+	} `json:"masteries"`
+	Spell2ID   int `json:"spell2Id"`
+	TeamID     int `json:"teamId"`
+	ChampionID int `json:"championId"`
+	Spell1Full string
+	Spell2Full string
+
+	//Timeline is Riot's guess at where this participant played, used to find lane matchups between the two teams
+	Timeline struct {
+		Lane string `json:"lane"`
+		Role string `json:"role"`
+	} `json:"timeline"`
+
+	//Derived is filled in by MatchStats.derive, never by Riot
+	Derived DerivedStats `json:"derived"`
+}
+
+type ParticipantStats struct {
+	HighestStreak                   string
+	Item1                           int  `json:"item1"`
+	TotalPlayerScore                int  `json:"totalPlayerScore"`
+	VisionScore                     int  `json:"visionScore"`
+	UnrealKills                     int  `json:"unrealKills"`
+	Win                             bool `json:"win"`
+	ObjectivePlayerScore            int  `json:"objectivePlayerScore"`
+	LargestCriticalStrike           int  `json:"largestCriticalStrike"`
+	TotalDamageDealt                int  `json:"totalDamageDealt"`
+	MagicDamageDealtToChampions     int  `json:"magicDamageDealtToChampions"`
+	LargestMultiKill                int  `json:"largestMultiKill"`
+	LargestKillingSpree             int  `json:"largestKillingSpree"`
+	QuadraKills                     int  `json:"quadraKills"`
+	TotalTimeCrowdControlDealt      int  `json:"totalTimeCrowdControlDealt"`
+	MagicalDamageTaken              int  `json:"magicalDamageTaken"`
+	LongestTimeSpentLiving          int  `json:"longestTimeSpentLiving"`
+	NeutralMinionsKilledEnemyJungle int  `json:"neutralMinionsKilledEnemyJungle"`
+	FirstTowerAssist                bool `json:"firstTowerAssist"`
+	NeutralMinionsKilledTeamJungle  int  `json:"neutralMinionsKilledTeamJungle"`
+	GoldEarned                      int  `json:"goldEarned"`
+	Item2                           int  `json:"item2"`
+	Item3                           int  `json:"item3"`
+	Item0                           int  `json:"item0"`
+	Deaths                          int  `json:"deaths"`
+	Item6                           int  `json:"item6"`
+	WardsPlaced                     int  `json:"wardsPlaced"`
+	Item4                           int  `json:"item4"`
+	Item5                           int  `json:"item5"`
+	TurretKills                     int  `json:"turretKills"`
+	TripleKills                     int  `json:"tripleKills"`
+	DamageSelfMitigated             int  `json:"damageSelfMitigated"`
+	GoldSpent                       int  `json:"goldSpent"`
+	MagicDamageDealt                int  `json:"magicDamageDealt"`
+	Kills                           int  `json:"kills"`
+	DoubleKills                     int  `json:"doubleKills"`
+	FirstInhibitorKill              bool `json:"firstInhibitorKill"`
+	TrueDamageTaken                 int  `json:"trueDamageTaken"`
+	FirstBloodAssist                bool `json:"firstBloodAssist"`
+	FirstBloodKill                  bool `json:"firstBloodKill"`
+	Assists                         int  `json:"assists"`
+	TotalScoreRank                  int  `json:"totalScoreRank"`
+	NeutralMinionsKilled            int  `json:"neutralMinionsKilled"`
+	CombatPlayerScore               int  `json:"combatPlayerScore"`
+	VisionWardsBoughtInGame         int  `json:"visionWardsBoughtInGame"`
+	DamageDealtToTurrets            int  `json:"damageDealtToTurrets"`
+	PhysicalDamageDealtToChampions  int  `json:"physicalDamageDealtToChampions"`
+	PentaKills                      int  `json:"pentaKills"`
+	TrueDamageDealt                 int  `json:"trueDamageDealt"`
+	TrueDamageDealtToChampions      int  `json:"trueDamageDealtToChampions"`
+	ChampLevel                      int  `json:"champLevel"`
+	ParticipantID                   int  `json:"participantId"`
+	FirstInhibitorAssist            bool `json:"firstInhibitorAssist"`
+	WardsKilled                     int  `json:"wardsKilled"`
+	FirstTowerKill                  bool `json:"firstTowerKill"`
+	TotalHeal                       int  `json:"totalHeal"`
+	TotalMinionsKilled              int  `json:"totalMinionsKilled"`
+	PhysicalDamageDealt             int  `json:"physicalDamageDealt"`
+	DamageDealtToObjectives         int  `json:"damageDealtToObjectives"`
+	SightWardsBoughtInGame          int  `json:"sightWardsBoughtInGame"`
+	TotalDamageDealtToChampions     int  `json:"totalDamageDealtToChampions"`
+	TotalUnitsHealed                int  `json:"totalUnitsHealed"`
+	InhibitorKills                  int  `json:"inhibitorKills"`
+	TotalDamageTaken                int  `json:"totalDamageTaken"`
+	KillingSprees                   int  `json:"killingSprees"`
+	TimeCCingOthers                 int  `json:"timeCCingOthers"`
+	PhysicalDamageTaken             int  `json:"physicalDamageTaken"`
 }
 
 type Profile struct {
@@ -201,144 +288,54 @@ type SpellInfo struct {
 	} `json:"image"`
 }
 
-//The web template we'll be using for our search webpage.
-const internalTempl = `
-<title>{{.SummonerName}}'s Stats - Ivern</title>
-<style>
-body{
-	background-color: #393939;
-	color: #FFFFFF;
-}
-h1{
-	text-align: center;
-}
-h1, h2{
-    font-family: sans-serif;
-}
-table{
-	font-family: sans-serif;
-	padding: 10px;
-}
-td{
-    text-align: center;
-    vertical-align: middle;
-    padding: 7px;
-}
-a:link {
-    color: LightGreen;
-}
-a:visited{
-	color: LightGreen;
-}
-</style>
-<body>
-<form method="POST" action="/search">
-     <input type="text" name="Search" placeholder="Summoner Name" autocomplete="off" required/> <input class="submit" type="submit" value="Search Summoner"/>
-</form>
-<h1>{{ .SummonerName }} <img src="http://ddragon.leagueoflegends.com/cdn/7.16.1/img/profileicon/{{.ProfileIconID}}.png" height=70px width=70px> </h1>
-<h1>Highest Rank this Season: {{ .HighestAchievedSeasonTier }}</h1> <br/><br/>
-Here's your match history:<br/>
-	
-{{range .Match}}
-{{$PartID := .Stats.ParticipantID}}
-_________________________________________________________________________________________________<br/>
-<h2 div="ChampionHeader"><img src="http://ddragon.leagueoflegends.com/cdn/7.16.1/img/champion/{{.Name}}.png" height="60px" width="60px"></img> <i>{{.Name}}</i></h2> <a href="http://matchhistory.na.leagueoflegends.com/en/#match-details/NA1/{{.GameID}}/{{$.AccountID}}?tab=overview">Link to Official Stats!</a> 
-			
-			{{range .Stats.Participants}}
-				{{if eq $PartID .ParticipantID}}
-				<table border=1 {{if .Stats.Win}} bordercolor="GREEN" {{else}} bordercolor="RED" {{end}}>	
-					<tr>
-						<td rowspan=2><img src="http://ddragon.leagueoflegends.com/cdn/7.16.1/img/spell/{{ .Spell1Full }}" height="60px" width="60px"></img><br/>
-						<img src="http://ddragon.leagueoflegends.com/cdn/7.16.1/img/spell/{{ .Spell2Full }}" height="60px" width="60px"></img></td>
-						
-						<td> <img src="http://ddragon.leagueoflegends.com/cdn/5.5.1/img/ui/score.png"></img><br/>
-							 <b div="kda"> {{ .Stats.Kills }} / {{ .Stats.Deaths }} / {{ .Stats.Assists }} </b></td>
-						
-						<td>
-						{{if .Stats.Win }}
-							<b>Victory!</b> <br/>
-							Ranked Draft Mode (5v5)
-						{{else}}
-							<b>Defeat!</b> <br/>
-							Ranked Draft Mode (5v5)
-						{{end}}
-						</td>
-						<td>
-							<i><b>{{.Stats.HighestStreak}}</b></i>
-						</td>
-						<td rowspan=2>
-						<img src="http://ddragon.leagueoflegends.com/cdn/5.5.1/img/ui/items.png"></img> <br/>
-							{{if gt .Stats.Item0 0}}
-							<img src="http://ddragon.leagueoflegends.com/cdn/7.16.1/img/item/{{ .Stats.Item0 }}.png" width="60px" height="60px"></img>
-							{{end}}
-							{{if gt .Stats.Item1 0}}
-							<img src="http://ddragon.leagueoflegends.com/cdn/7.16.1/img/item/{{ .Stats.Item1 }}.png" width="60px" height="60px"></img>
-							{{end}}
-							{{if gt .Stats.Item2 0}}
-							<img src="http://ddragon.leagueoflegends.com/cdn/7.16.1/img/item/{{ .Stats.Item2 }}.png" width="60px" height="60px"></img>
-							{{end}}<br/>
-							{{if gt .Stats.Item3 0}}
-							<img src="http://ddragon.leagueoflegends.com/cdn/7.16.1/img/item/{{ .Stats.Item3 }}.png" width="60px" height="60px"></img>
-							{{end}}
-							{{if gt .Stats.Item4 0}}
-							<img src="http://ddragon.leagueoflegends.com/cdn/7.16.1/img/item/{{ .Stats.Item4 }}.png" width="60px" height="60px"></img>
-							{{end}}
-							{{if gt .Stats.Item5 0}}
-							<img src="http://ddragon.leagueoflegends.com/cdn/7.16.1/img/item/{{ .Stats.Item5 }}.png" width="60px" height="60px"></img>
-							{{end}}
-							{{if gt .Stats.Item6 0}}
-							<img src="http://ddragon.leagueoflegends.com/cdn/7.16.1/img/item/{{ .Stats.Item6 }}.png" width="60px" height="60px"></img>
-							{{end}}
-						</td>
-					</tr>
-					<tr>
-						<td>
-						<img src="http://ddragon.leagueoflegends.com/cdn/5.5.1/img/ui/minion.png"></img><br/>
-						<b div="kda">{{ .Stats.TotalMinionsKilled }} CS</b>
-						</td>
-						<td>
-						<img src="http://ddragon.leagueoflegends.com/cdn/5.5.1/img/ui/gold.png"></img><br/>
-						{{ .Stats.GoldEarned }}
-						</td>
-						<td>
-						<img src="http://ddragon.leagueoflegends.com/cdn/5.5.1/img/ui/champion.png"></img><br/>
-						Level: {{ .Stats.ChampLevel }}
-						</td>
-					</tr>
-				</table>
-				{{end}}
-			{{end}}
-		
-{{ end }}
-</body>
-`
-
 //API-Key for Riot Developers
 const apiKey string
 
-//Our http Client which will be making the API Calls
-var client = &http.Client{}
+//Our http Client which will be making the API Calls. Each call has its own shorter timeout, see riotFetch, so this is only a backstop.
+var client = &http.Client{Timeout: 30 * time.Second}
 
-//Main variables
-var out Output
-var summonerName string
-
-//Temporary variables to filter information for the Main variables
-var record Profile
-var list playerMatches
-var champ Champion
-var spell SpellInfo
+//errNoSummoner is what summonerSearch returns when Riot has never heard of the name
+var errNoSummoner = errors.New("no summoner found")
 
 func main() {
 
-	fmt.Println("Serving Files")
+	//Everything, including starting the website, is a subcommand. See cli.go.
+	if err := runCommand(os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+}
+
+//serve starts the website as config says. It returns nil once it's been told to shut down and has finished, or an error if it couldn't listen.
+func serve(config serverConfig) error {
+
+	//Every page is parsed up front, so a broken template stops us here instead of on somebody's search
+	if err := loadTemplates(config.Templates); err != nil {
+		return err
+	}
+
 	//HandleFunc in Golang is used to look for the ending of the URL. It's told what the paramaters are, and then executes a function
 	//One difference is that if a parameter is sent at the end of the URL, and it's not explicitely listed below, it will execute the function closest to it's call
 	// URL.com/s, for instance, will send URL.com/search, unless it's explicitely told not to.
 	http.HandleFunc("/", homeFunc)
 	http.HandleFunc("/search", searchFunc)
-
-	http.ListenAndServe(":8080", nil)
+	http.HandleFunc("/champions", championFunc)
+	http.HandleFunc("/api/search", apiSearchFunc)
+	http.HandleFunc("/match", matchFunc)
+	http.HandleFunc("/mastery", masteryFunc)
+	http.HandleFunc("/live", liveFunc)
+	http.HandleFunc("/multi", multiSearchFunc)
+	http.HandleFunc("/compare", compareFunc)
+	http.HandleFunc("/teammates", teammatesFunc)
+	http.HandleFunc("/matchups", matchupsFunc)
+	http.HandleFunc("/export", exportFunc)
+	http.HandleFunc("/debug/cache", cacheFunc)
+	http.HandleFunc("/metrics", metricsFunc)
+	http.HandleFunc("/healthz", healthzFunc)
+	http.HandleFunc("/readyz", readyzFunc)
+
+	return runServer(config, withRequestID(withTracing(withDeadline(http.DefaultServeMux))))
 
 }
 
@@ -347,12 +344,9 @@ func main() {
 //to the webpage.
 func homeFunc(response http.ResponseWriter, request *http.Request) {
 
-	//Takes the homepage, index.html, and sends it to the client as a template. This allows for changes in the future if I want
-	//to send information to it later.
-	t, _ := template.ParseFiles("index.html")
-
-	//Executes the template, but does not send any information for input.
-	t.Execute(response, nil)
+	//Sends the homepage to the client as a template. This allows for changes in the future if I want
+	//to send information to it later, but for now it does not get any information for input.
+	renderPage(request.Context(), response, "home", nil)
 
 }
 
@@ -366,273 +360,605 @@ func searchFunc(response http.ResponseWriter, request *http.Request) {
 
 	} else {
 
-		//Collects the form information pushed from the homepage, then stores it into out.SummonerName
+		//Collects the form information pushed from the homepage
 		request.ParseForm()
 
-		fmt.Println("---")
-		fmt.Println("username:", request.FormValue("Search"))
-		out.SummonerName = request.FormValue("Search")
-
-		fmt.Println(out.SummonerName)
+		logFrom(request.Context()).Debug("search", "name", request.FormValue("Search"))
 
-		//Calls the summonerSearch function, which does not need any input since the valuable information is already stored in out.SummonerName
-		summonerSearch()
+		//Calls the summonerSearch function, which hands back everything the page needs in out
+		out, err := summonerSearch(request.Context(), defaultRegion, request.FormValue("Search"))
 
-		if record.ID == 0 {
+		if err != nil {
 			//If no user is found, return to the homepage
-			fmt.Println("No Summoner Found / Possible Rate Limit hit?")
+			logFrom(request.Context()).Info("search found nothing", "err", err)
 			http.Redirect(response, request, "/", 301)
 			return
 
 		} else {
 
 			//Once every bit of information is extracted and placed into the variable "out," the variable is then executed and input into the template
-			t := template.New("main")
-			t, _ = t.Parse(internalTempl)
-			t.Execute(response, out)
+			renderPage(request.Context(), response, "search", out)
 
 		}
 
-		//reset the ID and SummonerName to nil values
-		out.SummonerName = ""
-		record.ID = 0
-
 	}
 
 }
 
-func summonerSearch() {
-
-	//This is the URL for the API Call, specifically for getting profile information for the user out.SummonerName
-	url := "https://na1.api.riotgames.com/lol/summoner/v3/summoners/by-name/" + out.SummonerName + "?api_key=" + apiKey
+//apiSearchFunc does the same search as searchFunc, but answers /api/search?name=<summoner> with JSON instead of a webpage.
+//Everything the page can show, including the derived stats, is in there.
+func apiSearchFunc(response http.ResponseWriter, request *http.Request) {
 
-	log.Println(url)
+	out, err := summonerSearch(request.Context(), defaultRegion, request.FormValue("name"))
 
-	//req will set the paramaters for the API Call
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		log.Fatal("NewRequest: ", err)
+	if err == errNoSummoner {
+		http.Error(response, "No Summoner Found", http.StatusNotFound)
 		return
 	}
-
-	//resp will enact the API Call, starting a link between the server and the remote server
-	resp, err := client.Do(req)
 	if err != nil {
-		log.Fatal("Do: ", err)
+		logFrom(request.Context()).Warn("search failed", "err", err)
+		http.Error(response, "Could not search for that summoner", http.StatusBadGateway)
 		return
 	}
 
-	//This if statement is just a fancy way to double error check, in case there are no responses from the remote server
-	//At the same time, it's breaking down the API call, returned as a JSON object, into the variable "record", which has a Profile type and structure.
-	if err := json.NewDecoder(resp.Body).Decode(&record); err != nil {
-		log.Panic(err)
+	response.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(response).Encode(out); err != nil {
+		logFrom(request.Context()).Warn("encoding response failed", "err", err)
 	}
 
-	//All relevant data will be pulled into the out variable, which is structured above at line 12
-	out.ProfileIconID = record.ProfileIconID
-	out.SummonerName = record.Name
-	out.AccountID = record.AccountID
+}
 
-	if record.ID == 0 {
-		return
+//summonerSearch looks up a summoner and everything the profile shows about them.
+//Every search gets its own Output, so any number of searches can run at the same time.
+//If Riot is having trouble, we show what we have stored instead, marked as stale.
+func summonerSearch(ctx context.Context, region string, name string) (out *Output, err error) {
+
+	ctx, span := startSpan(ctx, "summonerSearch", "region", region)
+	start := time.Now()
+	defer func() {
+		result := searchResult(out, err)
+		recordSearch(result, time.Since(start))
+		span.Set("result", result)
+		span.Fail(err)
+		span.End()
+	}()
+
+	out, err = riotSearch(ctx, region, name)
+	if isOutage(err) {
+		logFrom(ctx).Warn("riot unavailable, showing stored data", "err", err)
+		if stored, staleErr := staleSearch(ctx, region, name); staleErr == nil {
+			return stored, nil
+		}
 	}
 
-	//Closes the API call to save resources from piling up
-	resp.Body.Close()
+	return out, err
+}
 
-	//New API call to the URL below, requesting Match History. As there are API limits in place, and I have only been given access to Ranked matches,
-	//I have set the limit to 5 Matches per API Call.  If this is to change in the future, we can update the URL accordingly
-	url = "https://na1.api.riotgames.com/lol/match/v3/matchlists/by-account/" + strconv.Itoa(record.AccountID) + "?endIndex=5&beginIndex=0&api_key=" + apiKey
+//riotSearch is summonerSearch with everything fresh from Riot
+func riotSearch(ctx context.Context, region string, name string) (*Output, error) {
 
-	log.Println(url)
+	out := &Output{Region: region}
 
-	//Rinse and Repeat above
-	req, err = http.NewRequest("GET", url, nil)
+	//This is the API Call specifically for getting profile information for the summoner
+	record, err := findSummoner(ctx, region, name)
 	if err != nil {
-		log.Fatal("NewRequest: ", err)
-		return
+		return nil, err
 	}
 
-	resp, err = client.Do(req)
+	//All relevant data will be pulled into the out variable, which is structured at the top of this file
+	out.ProfileIconID = record.ProfileIconID
+	out.SummonerName = record.Name
+	out.AccountID = record.AccountID
+	out.SummonerID = record.ID
+
+	//The current rank comes from its own endpoint. Without it the page still works, it just shows the summoner as unranked.
+	entries, err := getLeagueEntries(ctx, region, record.ID)
 	if err != nil {
-		log.Fatal("Do: ", err)
-		return
+		logFrom(ctx).Warn("league entries lookup failed", "err", err)
 	}
+	out.Ranked = ranked(entries)
 
-	//Directly Decode the important information into out
-	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
-		log.Panic(err)
+	//A 404 here just means they aren't in a game, which getActiveGame turns into a nil game
+	out.LiveGame, err = getActiveGame(ctx, region, record.ID)
+	if err != nil {
+		logFrom(ctx).Warn("active game lookup failed", "err", err)
+	}
+
+	//Every sync adds to the summoner's rank history, which is what the LP graph is drawn from
+	if err := recordSnapshot(out.Region, out.AccountID, out.Ranked, time.Now()); err != nil {
+		logFrom(ctx).Warn("lp history failed", "err", err)
 	}
 
-	resp.Body.Close()
+	//New API call requesting Match History. As there are API limits in place, and I have only been given access to Ranked matches,
+	//I have set the limit to 5 Matches per API Call.  If this is to change in the future, we can update the count accordingly
+	if err := getMatchList(ctx, out, 5); err != nil {
+		return nil, err
+	}
 
 	//Since each Match is stored into out.Match[], we will need to pull the information that we want to display.
 	//Along with this, the Champion that was played was given to us in an Int. We will need to send that information back
 	//in order to get the Champion's name as well. We do this for each match using a for loop for the size (or LENgth) of out.Match[]
 	for i := 0; i < len(out.Match); i++ {
 
-		//getChampionName requires the Champion ID, stored in list.Match[i].Champion, and the iteration of the loop, i, to ensure
+		//getChampionName requires the Champion ID, stored in out.Match[i].Champion, and the iteration of the loop, i, to ensure
 		//that the information is stored to its coresponding match.
-		getChampionName(out.Match[i].Champion, i)
+		if err := getChampionName(ctx, out, i); err != nil {
+			logFrom(ctx).Warn("champion name lookup failed", "err", err)
+		}
 
-		fmt.Print(out.Match[i].Name + " ")
-		fmt.Println(strconv.FormatInt(out.Match[i].GameID, 10))
+		logFrom(ctx).Debug("match", "champion", out.Match[i].Name, "game_id", out.Match[i].GameID)
 
 		//Lastly, we need the Match information for each individual Match.
-		//Just like getChampionName, getMatchInfo requires the MatchID as well as the iteration of the loop.
-		getMatchInfo(out.Match[i].GameID, i)
+		//Just like getChampionName, getMatchInfo requires the iteration of the loop to find the MatchID.
+		if err := getMatchInfo(ctx, out, i); err != nil {
+			return nil, err
+		}
+
+	}
 
+	//With the newest matches safely in the archive, we can build the champion table from everything we've ever stored for this account
+	_, span := startSpan(ctx, "archive load", "account_id", out.AccountID)
+	matches, err := loadArchive(out.Region, out.AccountID)
+	span.Set("matches", len(matches))
+	span.Fail(err)
+	span.End()
+	if err != nil {
+		logFrom(ctx).Warn("archive access failed", "err", err)
 	}
+	archiveTables(ctx, out, matches)
+
+	return out, nil
 
 }
 
-func getChampionName(x int, i int) {
-	//New URL specifically for Champion information.
-	url := "https://na1.api.riotgames.com/lol/static-data/v3/champions/" + strconv.Itoa(x) + "?locale=en_US&tags=image&api_key=" + apiKey
+//archiveTables fills in everything on the profile that comes from the archive rather than from Riot
+func archiveTables(ctx context.Context, out *Output, matches []MatchInfo) {
+
+	out.Champions = ChampionTable{
+		SummonerName: out.SummonerName,
+		AccountID:    out.AccountID,
+		MinGames:     1,
+		Champions:    championBreakdown(matches, "games", 1),
+	}
+	out.Positions = positionBreakdown(matches)
 
-	req, err := http.NewRequest("GET", url, nil)
+	snapshots, err := loadSnapshots(out.Region, out.AccountID)
 	if err != nil {
-		log.Fatal("NewRequest: ", err)
-		return
+		logFrom(ctx).Warn("lp history failed", "err", err)
 	}
+	out.LP = lpHistory(snapshots, matches)
 
-	resp, err := client.Do(req)
+}
+
+//getChampionName fills in the name of the champion played in out.Match[i]
+func getChampionName(ctx context.Context, out *Output, i int) error {
+
+	_, span := startSpan(ctx, "getChampionName", "champion_id", out.Match[i].Champion)
+	defer span.End()
+
+	name, err := championName(out.Match[i].Champion)
 	if err != nil {
-		log.Fatal("Do: ", err)
-		return
+		return err
 	}
 
-	//Decode into champ, since we only need the Name of the champion. We don't need to output all of the information to out.
-	if err := json.NewDecoder(resp.Body).Decode(&champ); err != nil {
-		log.Panic(err)
+	//Send the information to the specific Match in out
+	out.Match[i].Name = name
+	return nil
+}
+
+//championNames remembers every champion name we've looked up, since they only change when Riot adds a champion
+var championNames = struct {
+	sync.Mutex
+	byID map[int]string
+}{byID: make(map[int]string)}
+
+//championName looks up a champion's name by ID without touching out, for pages that show champions other than the searched summoner's.
+//The first miss fetches the whole champion list in one call, since pages like mastery need nearly every champion anyway.
+func championName(id int) (string, error) {
+
+	championNames.Lock()
+	name, ok := championNames.byID[id]
+	championNames.Unlock()
+	if ok {
+		return name, nil
 	}
 
-	//Send the information to the specific Match in out, then close the API Call to save resources
-	out.Match[i].Name = champ.Name
-	resp.Body.Close()
+	//The list is shared by every search, so one closed tab shouldn't stop it loading for everybody else
+	if err := loadChampionNames(context.Background()); err != nil {
+		return "", err
+	}
+
+	championNames.Lock()
+	name, ok = championNames.byID[id]
+	championNames.Unlock()
+
+	if !ok {
+		return "", fmt.Errorf("unknown champion %d", id)
+	}
+
+	return name, nil
 }
 
-func getMatchInfo(x int64, i int) {
+//loadChampionNames fetches the whole champion list into championNames
+func loadChampionNames(ctx context.Context) error {
 
-	//Here, we make a call to get previous match stats.  This includes K / D / A, Victory/Defeat, Creep Score, and everything else.
-	url := "https://na1.api.riotgames.com/lol/match/v3/matches/" + strconv.FormatInt(x, 10) + "?api_key=" + apiKey
+	var list struct {
+		Data map[string]Champion `json:"data"`
+	}
+	//Champion IDs are the same everywhere, so it doesn't matter which region we ask
+	url := riotURL(defaultRegion, "/lol/static-data/v3/champions?locale=en_US&dataById=true")
+	if err := riotGet(ctx, url, &list); err != nil {
+		return err
+	}
+
+	championNames.Lock()
+	for _, c := range list.Data {
+		championNames.byID[c.ID] = c.Name
+	}
+	championNames.Unlock()
+
+	return nil
+}
+
+//championsLoaded says whether the champion list has made it in yet, which /readyz waits on
+func championsLoaded() bool {
+	championNames.Lock()
+	defer championNames.Unlock()
+	return len(championNames.byID) > 0
+}
+
+//getSummoner looks up a summoner's profile by name without touching out or record, for pages that only need the IDs
+func getSummoner(ctx context.Context, region string, name string) (Profile, error) {
+
+	var p Profile
+	err := riotGet(ctx, summonerURL(region, name), &p)
+
+	return p, err
+}
+
+func summonerURL(region string, name string) string {
+	return riotURL(region, "/lol/summoner/v3/summoners/by-name/"+url.PathEscape(name))
+}
+
+//platforms maps the region names people type to the platform part of Riot's API hosts
+var platforms = map[string]string{
+	"na":   "na1",
+	"euw":  "euw1",
+	"eune": "eun1",
+	"kr":   "kr",
+	"jp":   "jp1",
+	"br":   "br1",
+	"lan":  "la1",
+	"las":  "la2",
+	"oce":  "oc1",
+	"tr":   "tr1",
+	"ru":   "ru",
+}
 
-	fmt.Println(url)
+//The website only searches NA. The command line can pick any region.
+const defaultRegion = "na"
 
-	req, err := http.NewRequest("GET", url, nil)
+//riotURL builds the full address for an API path on a region's host, with our API key on the end.
+//An unknown region falls back to NA rather than failing, so callers should check the name against platforms first.
+func riotURL(region string, path string) string {
+
+	platform, ok := platforms[region]
+	if !ok {
+		platform = platforms[defaultRegion]
+	}
+
+	separator := "?"
+	if strings.Contains(path, "?") {
+		separator = "&"
+	}
+
+	return "https://" + platform + ".api.riotgames.com" + path + separator + "api_key=" + apiKey
+}
+
+//getMatchList fills out.Match with the summoner's most recent count games, newest first
+func getMatchList(ctx context.Context, out *Output, count int) error {
+
+	url := riotURL(out.Region, "/lol/match/v3/matchlists/by-account/"+strconv.Itoa(out.AccountID)+"?endIndex="+strconv.Itoa(count)+"&beginIndex=0")
+
+	//Directly Decode the important information into out
+	return riotGet(ctx, url, out)
+}
+
+//findSummoner is getSummoner, except a name Riot doesn't know comes back as errNoSummoner
+func findSummoner(ctx context.Context, region string, name string) (Profile, error) {
+
+	record, err := getSummoner(ctx, region, name)
+	if isNotFound(err) || (err == nil && record.ID == 0) {
+		return record, errNoSummoner
+	}
+
+	return record, err
+}
+
+//apiError is what riotGet hands back when Riot answers with anything other than 200 OK.
+//Some endpoints use 404 to mean "nothing to show" rather than a real failure, so callers can check the status.
+type apiError struct {
+	Status int
+}
+
+func (e *apiError) Error() string {
+	return "riot api: " + strconv.Itoa(e.Status) + " " + http.StatusText(e.Status)
+}
+
+//isNotFound reports whether err is Riot telling us there's nothing at that URL
+func isNotFound(err error) bool {
+	e, ok := err.(*apiError)
+	return ok && e.Status == http.StatusNotFound
+}
+
+//riotGet makes a single API call and decodes the JSON answer into v.
+//Unlike the calls above, a failure here is handed back to the caller instead of stopping the whole server.
+//Anything the cache has a fresh copy of is answered without asking Riot at all, and if the same URL is
+//already being fetched for another search we wait for that answer instead of asking twice.
+//Once ctx is done (say, the user closed the tab) the call gives up, and so does anything else the search had left to fetch.
+func riotGet(ctx context.Context, url string, v interface{}) error {
+
+	_, span := startSpan(ctx, "cache lookup", "endpoint", cacheRuleFor(url).Name)
+	body, ok := riotCache.get(url)
+	span.Set("hit", ok)
+	span.End()
+	if ok {
+		return json.Unmarshal(body, v)
+	}
+
+	for {
+		body, err, shared := riotFlights.do(ctx, cacheKey(url), func() ([]byte, error) {
+			//While the breaker is open we don't bother Riot at all, and callers fall back to stored data
+			if !riotBreaker.allow() {
+				return nil, errRiotUnavailable
+			}
+
+			body, err := riotFetch(ctx, url)
+			//A search that was cancelled says nothing about whether Riot is healthy
+			if ctx.Err() == nil {
+				riotBreaker.record(err)
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			//Only answers that are proper JSON are worth keeping
+			if !json.Valid(body) {
+				return nil, errors.New("riot api: answer is not valid JSON")
+			}
+			if err := riotCache.put(url, body); err != nil {
+				logFrom(ctx).Warn("cache write failed", "err", err)
+			}
+			return body, nil
+		})
+
+		//If we were waiting on someone else's call and they went away, our own search still wants the answer, so we ask again.
+		//Only their search being cancelled counts: a call that timed out already had all its tries, and asking again would just hang again.
+		if shared && errors.Is(err, context.Canceled) && ctx.Err() == nil {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		return json.Unmarshal(body, v)
+	}
+}
+
+//Every call gets callTimeout per try, and is tried up to riotTries times when it fails in a way that might work next time.
+//The timings are variables so tests don't have to wait them out.
+const riotTries = 3
+
+var (
+	callTimeout = 10 * time.Second
+	retryDelay  = 250 * time.Millisecond
+)
+
+//isCancelled reports whether err is a context giving up rather than anything Riot did
+func isCancelled(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+//retryable reports whether a failed call is safe to try again: server errors and the network failing.
+//Anything else, like a 404 or a rate limit, will just fail the same way again.
+func retryable(err error) bool {
+	if e, ok := err.(*apiError); ok {
+		return e.Status >= 500
+	}
+	return err != nil
+}
+
+//riotFetch is the actual trip to Riot, handing back the raw body of a 200 OK.
+//Retries wait twice as long each time, plus up to the same again at random so that searches that failed together don't all retry together.
+func riotFetch(ctx context.Context, url string) ([]byte, error) {
+
+	var err error
+	for try := 0; try < riotTries; try++ {
+		if try > 0 {
+			delay := retryDelay << uint(try-1)
+			delay += time.Duration(rand.Int63n(int64(delay)))
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+
+		var body []byte
+		body, err = riotFetchOnce(ctx, url)
+		if err == nil || !retryable(err) || ctx.Err() != nil {
+			return body, err
+		}
+	}
+
+	return nil, err
+}
+
+func riotFetchOnce(ctx context.Context, url string) ([]byte, error) {
+
+	ctx, span := startSpan(ctx, "riot "+cacheRuleFor(url).Name, "endpoint", cacheRuleFor(url).Name)
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, callTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
-		log.Fatal("NewRequest: ", err)
-		return
+		return nil, err
 	}
 
+	start := time.Now()
 	resp, err := client.Do(req)
+	err = withoutURL(err)
+	observeUpstream(ctx, url, resp, err, time.Since(start))
 	if err != nil {
-		log.Fatal("Do: ", err)
-		return
+		span.Fail(err)
+		return nil, err
+	}
+	span.Set("http.status_code", resp.StatusCode)
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		span.Fail(&apiError{Status: resp.StatusCode})
+		return nil, &apiError{Status: resp.StatusCode}
 	}
 
+	return io.ReadAll(resp.Body)
+}
+
+func getMatchInfo(ctx context.Context, out *Output, i int) (err error) {
+
+	x := out.Match[i].GameID
+
+	ctx, span := startSpan(ctx, "getMatchInfo", "game_id", x)
+	defer func() {
+		span.Fail(err)
+		span.End()
+	}()
+
+	//Here, we make a call to get previous match stats.  This includes K / D / A, Victory/Defeat, Creep Score, and everything else.
+	url := riotURL(out.Region, "/lol/match/v3/matches/"+strconv.FormatInt(x, 10))
+
 	//Decode everything into out.Match[i].Stats. While we won't be using all of it, this allows expandability in the future
 	//in case I wish to display more stats on the page.
-	if err := json.NewDecoder(resp.Body).Decode(&out.Match[i].Stats); err != nil {
-		log.Fatal("Error decoding stats")
+	if err := riotGet(ctx, url, &out.Match[i].Stats); err != nil {
+		return err
 	}
 
-	var p int
+	//p starts at -1 so we can tell "not found" apart from the first participant
+	p := -1
+	out.Match[i].Stats.ParticipantID = 0
+	out.Match[i].Unmatched = false
 
 	//Here, we are only looking for the correct iteration that matches the original player we searched for.
 	//Since there are 10 players total, we want to search through each of them until we find our specific player.
+	//Summoner names can change, but the account ID never does, so that's what we compare. CurrentAccountID covers players who transferred regions.
 
 	//Using a temporary value to hold the correct Participant iteration (p), we subtract 1 because the slice starts at 0, while the ID value starts at 1
 	for y := 0; y < len(out.Match[i].Stats.ParticipantIdentities); y++ {
-		if out.SummonerName == out.Match[i].Stats.ParticipantIdentities[y].Player.SummonerName {
+		player := out.Match[i].Stats.ParticipantIdentities[y].Player
+		if player.AccountID == out.AccountID || player.CurrentAccountID == out.AccountID {
 			out.Match[i].Stats.ParticipantID = out.Match[i].Stats.ParticipantIdentities[y].ParticipantID
 			p = out.Match[i].Stats.ParticipantIdentities[y].ParticipantID - 1
-			continue
+			break
+		}
+	}
+
+	//If we can't find the player, showing someone else's stats would be worse than showing nothing, so the match is flagged instead
+	if p < 0 || p >= len(out.Match[i].Stats.Participants) {
+		logFrom(ctx).Warn("account not found in match", "account_id", out.AccountID, "game_id", x)
+		out.Match[i].Unmatched = true
+		p = -1
+	}
+
+	//Store the match exactly as Riot sent it (plus which participant we are) before we start adjusting numbers for the webpage
+	_, write := startSpan(ctx, "archive write", "game_id", x)
+	if err := archiveMatch(out.Region, out.AccountID, out.Match[i]); err != nil {
+		write.Fail(err)
+		logFrom(ctx).Warn("archive access failed", "err", err)
+	}
+	write.End()
+
+	//Timelines are big and only needed on the match page, but like matches they never change, so we only ever fetch each one once
+	if !hasTimeline(out.Region, x) {
+		var tl Timeline
+		if err := riotGet(ctx, riotURL(out.Region, "/lol/match/v3/timelines/by-match/"+strconv.FormatInt(x, 10)), &tl); err != nil {
+			logFrom(ctx).Warn("timeline failed", "err", err)
+		} else if err := archiveTimeline(out.Region, x, tl); err != nil {
+			logFrom(ctx).Warn("archive access failed", "err", err)
 		}
 	}
 
-	//Send the HAST to out's basic call to remove any possible errors
-	out.HighestAchievedSeasonTier = out.Match[i].Stats.Participants[p].HighestAchievedSeasonTier
+	//Work out the per-minute and team share numbers (including the real Creep Score) for everyone in the match
+	out.Match[i].Stats.derive()
 
-	//TotalMinionsKilled only counts basic minions. To display the correct Creep Score,
-	//we need to add TotalMinionsKilled as well as NeutralMinionsKilled
-	out.Match[i].Stats.Participants[p].Stats.TotalMinionsKilled = out.Match[i].Stats.Participants[p].Stats.TotalMinionsKilled + out.Match[i].Stats.Participants[p].Stats.NeutralMinionsKilled
+	out.Match[i].decorate()
+
+	return nil
+
+}
+
+//decorate fills in the bits of the summoner's own row that only the profile page uses.
+//Archived matches are stored before this happens, so matches loaded back from the archive need it done again.
+func (m *MatchInfo) decorate() {
+
+	p := m.Stats.ParticipantID - 1
+	if m.Unmatched || p < 0 || p >= len(m.Stats.Participants) {
+		return
+	}
 
 	//Here, we assign file names to the Summoner Spells so that we may call them later in the webpage via the template.
 	//We use p to ensure that we're on the same player as before
 
-	switch out.Match[i].Stats.Participants[p].Spell1ID {
-	case 1:
-		out.Match[i].Stats.Participants[p].Spell1Full = "SummonerBoost.png"
+	m.Stats.Participants[p].Spell1Full = spellImage(m.Stats.Participants[p].Spell1ID)
+	m.Stats.Participants[p].Spell2Full = spellImage(m.Stats.Participants[p].Spell2ID)
+
+	//Lastly, we want the player's highest killstreak so that we can display it on the webpage! For bragging rights, of course.
+	switch m.Stats.Participants[p].Stats.LargestMultiKill {
+	default:
+		m.Stats.Participants[p].Stats.HighestStreak = "No Multikill"
+	case 2:
+		m.Stats.Participants[p].Stats.HighestStreak = "Double Kill"
 	case 3:
-		out.Match[i].Stats.Participants[p].Spell1Full = "SummonerExhaust.png"
+		m.Stats.Participants[p].Stats.HighestStreak = "Triple Kill!"
 	case 4:
-		out.Match[i].Stats.Participants[p].Spell1Full = "SummonerFlash.png"
-	case 6:
-		out.Match[i].Stats.Participants[p].Spell1Full = "SummonerHaste.png"
-	case 7:
-		out.Match[i].Stats.Participants[p].Spell1Full = "SummonerHeal.png"
-	case 11:
-		out.Match[i].Stats.Participants[p].Spell1Full = "SummonerSmite.png"
-	case 12:
-		out.Match[i].Stats.Participants[p].Spell1Full = "SummonerTeleport.png"
-	case 13:
-		out.Match[i].Stats.Participants[p].Spell1Full = "SummonerMana.png"
-	case 14:
-		out.Match[i].Stats.Participants[p].Spell1Full = "SummonerDot.png"
-	case 21:
-		out.Match[i].Stats.Participants[p].Spell1Full = "SummonerBarrier.png"
-	case 30:
-		out.Match[i].Stats.Participants[p].Spell1Full = "SummonerPoroRecall.png"
-	case 31:
-		out.Match[i].Stats.Participants[p].Spell1Full = "SummonerPoroThrow.png"
-	case 32:
-		out.Match[i].Stats.Participants[p].Spell1Full = "SummonerSnowball.png"
+		m.Stats.Participants[p].Stats.HighestStreak = "Quadrakill!"
+	case 5:
+		m.Stats.Participants[p].Stats.HighestStreak = "PENTAKILL!"
 	}
+}
+
+//spellImage gives the image file for a Summoner Spell ID, or an empty string for a spell we don't know about
+func spellImage(id int) string {
 
-	switch out.Match[i].Stats.Participants[p].Spell2ID {
+	switch id {
 	case 1:
-		out.Match[i].Stats.Participants[p].Spell2Full = "SummonerBoost.png"
+		return "SummonerBoost.png"
 	case 3:
-		out.Match[i].Stats.Participants[p].Spell2Full = "SummonerExhaust.png"
+		return "SummonerExhaust.png"
 	case 4:
-		out.Match[i].Stats.Participants[p].Spell2Full = "SummonerFlash.png"
+		return "SummonerFlash.png"
 	case 6:
-		out.Match[i].Stats.Participants[p].Spell2Full = "SummonerHaste.png"
+		return "SummonerHaste.png"
 	case 7:
-		out.Match[i].Stats.Participants[p].Spell2Full = "SummonerHeal.png"
+		return "SummonerHeal.png"
 	case 11:
-		out.Match[i].Stats.Participants[p].Spell2Full = "SummonerSmite.png"
+		return "SummonerSmite.png"
 	case 12:
-		out.Match[i].Stats.Participants[p].Spell2Full = "SummonerTeleport.png"
+		return "SummonerTeleport.png"
 	case 13:
-		out.Match[i].Stats.Participants[p].Spell2Full = "SummonerMana.png"
+		return "SummonerMana.png"
 	case 14:
-		out.Match[i].Stats.Participants[p].Spell2Full = "SummonerDot.png"
+		return "SummonerDot.png"
 	case 21:
-		out.Match[i].Stats.Participants[p].Spell2Full = "SummonerBarrier.png"
+		return "SummonerBarrier.png"
 	case 30:
-		out.Match[i].Stats.Participants[p].Spell2Full = "SummonerPoroRecall.png"
+		return "SummonerPoroRecall.png"
 	case 31:
-		out.Match[i].Stats.Participants[p].Spell2Full = "SummonerPoroThrow.png"
+		return "SummonerPoroThrow.png"
 	case 32:
-		out.Match[i].Stats.Participants[p].Spell2Full = "SummonerSnowball.png"
-	}
-
-	//Lastly, we want the player's highest killstreak so that we can display it on the webpage! For bragging rights, of course.
-	switch out.Match[i].Stats.Participants[p].Stats.LargestMultiKill {
-	default:
-		out.Match[i].Stats.Participants[p].Stats.HighestStreak = "No Multikill"
-	case 2:
-		out.Match[i].Stats.Participants[p].Stats.HighestStreak = "Double Kill"
-	case 3:
-		out.Match[i].Stats.Participants[p].Stats.HighestStreak = "Triple Kill!"
-	case 4:
-		out.Match[i].Stats.Participants[p].Stats.HighestStreak = "Quadrakill!"
-	case 5:
-		out.Match[i].Stats.Participants[p].Stats.HighestStreak = "PENTAKILL!"
+		return "SummonerSnowball.png"
 	}
 
-	resp.Body.Close()
-
+	return ""
 }
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+//roundTripperFunc stands in for Riot, so tests never touch the network
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+//answer is a response from the fake Riot with the given status and body
+func answer(status int, body string) *http.Response {
+	return &http.Response{StatusCode: status, Header: make(http.Header), Body: io.NopCloser(strings.NewReader(body))}
+}
+
+//fakeRiot points every Riot call at transport, with an empty cache and a breaker that won't trip during the test.
+//Everything is put back when the test finishes.
+func fakeRiot(t *testing.T, transport roundTripperFunc) {
+
+	oldClient, oldCache, oldBreaker, oldFlights := client, riotCache, riotBreaker, riotFlights
+	t.Cleanup(func() {
+		client, riotCache, riotBreaker, riotFlights = oldClient, oldCache, oldBreaker, oldFlights
+	})
+
+	client = &http.Client{Transport: transport}
+	riotCache = newResponseCache(10, 1<<20)
+	riotBreaker = &circuitBreaker{threshold: 100, cooldown: time.Minute}
+	riotFlights = &flightGroup{calls: make(map[string]*flight)}
+}
+
+//Spectator answers are never cached, so every riotGet in these tests really goes to the fake Riot
+const liveTestURL = "https://na1.api.riotgames.com/lol/spectator/v3/active-games/by-summoner/1"
+
+func TestRiotGetRetries(t *testing.T) {
+
+	tests := []struct {
+		name      string
+		answers   []int
+		timeout   bool
+		wantCalls int
+		wantErr   bool
+	}{
+		{name: "ok", answers: []int{200}, wantCalls: 1},
+		{name: "server errors then ok", answers: []int{502, 503, 200}, wantCalls: 3},
+		{name: "not found is final", answers: []int{404}, wantCalls: 1, wantErr: true},
+		{name: "rate limit is final", answers: []int{429}, wantCalls: 1, wantErr: true},
+		{name: "server errors every time", answers: []int{500, 500, 500, 500}, wantCalls: riotTries, wantErr: true},
+		//Our own per-call timeout running out has to stop after riotTries, not start another round
+		{name: "every try times out", timeout: true, wantCalls: riotTries, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			var mu sync.Mutex
+			calls := 0
+			fakeRiot(t, func(req *http.Request) (*http.Response, error) {
+				mu.Lock()
+				defer mu.Unlock()
+				calls++
+				if tt.timeout {
+					return nil, context.DeadlineExceeded
+				}
+				return answer(tt.answers[calls-1], `{}`), nil
+			})
+
+			var v map[string]interface{}
+			err := riotGet(context.Background(), liveTestURL, &v)
+
+			if (err != nil) != tt.wantErr {
+				t.Errorf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("made %d calls, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestRiotGetLeaderCancelled(t *testing.T) {
+
+	var mu sync.Mutex
+	calls := 0
+	fakeRiot(t, func(req *http.Request) (*http.Response, error) {
+		mu.Lock()
+		calls++
+		first := calls == 1
+		mu.Unlock()
+
+		//The first call hangs until its search gives up, the one after that answers
+		if first {
+			<-req.Context().Done()
+			return nil, req.Context().Err()
+		}
+		return answer(200, `{"gameId": 7}`), nil
+	})
+
+	leader, cancel := context.WithCancel(context.Background())
+	leaderDone := make(chan error, 1)
+	go func() {
+		var v map[string]interface{}
+		leaderDone <- riotGet(leader, liveTestURL, &v)
+	}()
+	for riotFlights.inFlight() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	waiterDone := make(chan error, 1)
+	var got struct {
+		GameID int `json:"gameId"`
+	}
+	go func() {
+		waiterDone <- riotGet(context.Background(), liveTestURL, &got)
+	}()
+	for riotFlights.shared() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	cancel()
+	if err := <-leaderDone; err == nil {
+		t.Error("cancelled search got an answer")
+	}
+	if err := <-waiterDone; err != nil || got.GameID != 7 {
+		t.Errorf("waiter got %+v, %v, want to ask again and get the game", got, err)
+	}
+	if calls != 2 {
+		t.Errorf("made %d calls, want 2", calls)
+	}
+}
+
+//quickRetries shortens the per-call timeout and the backoff for one test
+func quickRetries(t *testing.T, timeout time.Duration, delay time.Duration) {
+	oldTimeout, oldDelay := callTimeout, retryDelay
+	t.Cleanup(func() { callTimeout, retryDelay = oldTimeout, oldDelay })
+	callTimeout, retryDelay = timeout, delay
+}
+
+func TestRiotGetHangingRiot(t *testing.T) {
+
+	quickRetries(t, 20*time.Millisecond, time.Millisecond)
+
+	var mu sync.Mutex
+	calls := 0
+	fakeRiot(t, func(req *http.Request) (*http.Response, error) {
+		mu.Lock()
+		calls++
+		mu.Unlock()
+
+		//Riot never answers, so only our own timeout ends each try
+		<-req.Context().Done()
+		return nil, req.Context().Err()
+	})
+
+	done := make(chan error, 1)
+	go func() {
+		var v map[string]interface{}
+		done <- riotGet(context.Background(), liveTestURL, &v)
+	}()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("got an answer from a Riot that never answers")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("riotGet is still waiting on a hung Riot")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if calls != riotTries {
+		t.Errorf("made %d calls, want %d", calls, riotTries)
+	}
+	if n := riotFlights.inFlight(); n != 0 {
+		t.Errorf("%d calls still in flight", n)
+	}
+}
+
+func TestRiotFetchBackoff(t *testing.T) {
+
+	quickRetries(t, time.Second, 20*time.Millisecond)
+
+	var mu sync.Mutex
+	var times []time.Time
+	fakeRiot(t, func(req *http.Request) (*http.Response, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		times = append(times, time.Now())
+		return answer(503, ``), nil
+	})
+
+	if _, err := riotFetch(context.Background(), liveTestURL); err == nil {
+		t.Fatal("got an answer from a Riot that only fails")
+	}
+
+	//Each wait is at least twice the one before, whatever the jitter adds
+	if len(times) != riotTries {
+		t.Fatalf("made %d calls, want %d", len(times), riotTries)
+	}
+	for i := 1; i < len(times); i++ {
+		waited := times[i].Sub(times[i-1])
+		least := retryDelay << uint(i-1)
+		if waited < least {
+			t.Errorf("waited %v before try %d, want at least %v", waited, i+1, least)
+		}
+	}
+
+	//A search that gives up while waiting to retry stops straight away
+	ctx, cancel := context.WithTimeout(context.Background(), retryDelay/2)
+	defer cancel()
+	start := time.Now()
+	if _, err := riotFetch(ctx, liveTestURL); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want the search's own deadline", err)
+	}
+	if took := time.Since(start); took >= retryDelay {
+		t.Errorf("took %v to give up, want less than the first retry delay", took)
+	}
+}
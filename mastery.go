@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+//ChampionMastery is one champion's entry from the champion mastery endpoint
+type ChampionMastery struct {
+	ChampionID                   int   `json:"championId"`
+	ChampionLevel                int   `json:"championLevel"`
+	ChampionPoints               int   `json:"championPoints"`
+	LastPlayTime                 int64 `json:"lastPlayTime"`
+	ChestGranted                 bool  `json:"chestGranted"`
+	TokensEarned                 int   `json:"tokensEarned"`
+	ChampionPointsSinceLastLevel int   `json:"championPointsSinceLastLevel"`
+	ChampionPointsUntilNextLevel int   `json:"championPointsUntilNextLevel"`
+}
+
+//MasteryRow is a mastery entry with the champion's name and, if we have any archived games on it, how they went
+type MasteryRow struct {
+	ChampionMastery
+	Name string
+
+	//Archive is nil when the summoner has no archived games on this champion
+	Archive *ChampionStats
+}
+
+func (r MasteryRow) LastPlayedDate() string {
+	return time.Unix(r.LastPlayTime/1000, 0).Format("Jan 2, 2006")
+}
+
+//getMastery asks for every champion the summoner has mastery on. Riot already sorts them by points, highest first.
+//Like the other summoner lookups it's v3, which takes the numeric summoner ID.
+func getMastery(ctx context.Context, region string, summonerID int) ([]ChampionMastery, error) {
+
+	var mastery []ChampionMastery
+	url := riotURL(region, "/lol/champion-mastery/v3/champion-masteries/by-summoner/"+strconv.Itoa(summonerID))
+	if err := riotGet(ctx, url, &mastery); err != nil {
+		return nil, err
+	}
+
+	return mastery, nil
+}
+
+//masteryRows joins mastery with the archive's champion table, so the page can show how much a champion has been played
+//next to how well it's actually going
+func masteryRows(ctx context.Context, mastery []ChampionMastery, matches []MatchInfo) []MasteryRow {
+
+	archived := make(map[int]ChampionStats)
+	for _, c := range championBreakdown(matches, "games", 1) {
+		archived[c.Champion] = c
+	}
+
+	rows := make([]MasteryRow, len(mastery))
+	for i, m := range mastery {
+		rows[i].ChampionMastery = m
+
+		name, err := championName(m.ChampionID)
+		if err != nil {
+			logFrom(ctx).Warn("champion name lookup failed", "err", err)
+			name = "Champion " + strconv.Itoa(m.ChampionID)
+		}
+		rows[i].Name = name
+
+		if c, ok := archived[m.ChampionID]; ok {
+			rows[i].Archive = &c
+		}
+	}
+
+	return rows
+}
+
+//masteryFunc serves /mastery?name=<summoner>
+func masteryFunc(response http.ResponseWriter, request *http.Request) {
+
+	profile, err := getSummoner(request.Context(), defaultRegion, request.FormValue("name"))
+	if err != nil || profile.ID == 0 {
+		http.Error(response, "No Summoner Found", http.StatusNotFound)
+		return
+	}
+
+	mastery, err := getMastery(request.Context(), defaultRegion, profile.ID)
+	if err != nil {
+		logFrom(request.Context()).Warn("mastery lookup failed", "err", err)
+		http.Error(response, "Could not load champion mastery", http.StatusBadGateway)
+		return
+	}
+
+	matches, err := loadArchive(defaultRegion, profile.AccountID)
+	if err != nil {
+		logFrom(request.Context()).Warn("archive access failed", "err", err)
+	}
+
+	page := struct {
+		SummonerName  string
+		ProfileIconID int
+		Rows          []MasteryRow
+	}{
+		SummonerName:  profile.Name,
+		ProfileIconID: profile.ProfileIconID,
+		Rows:          masteryRows(request.Context(), mastery, matches),
+	}
+
+	renderPage(request.Context(), response, "mastery", page)
+
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+)
+
+//FrameDiff is how far ahead (or behind, when negative) the summoner was over their lane opponent at one point in the game
+type FrameDiff struct {
+	Gold  int
+	CS    int
+	XP    int
+	Level int
+}
+
+//LaneDiff is the summoner against their direct lane opponent in one match.
+//Every number is the summoner's minus the opponent's, so positive means they won that part of the lane.
+type LaneDiff struct {
+	GameID   int64
+	Position string
+	Champion string
+	Opponent string
+	Win      bool
+
+	CS     int
+	Gold   int
+	Damage int
+	Kills  int
+	Level  int
+
+	//At10 and At15 are only there when we have a timeline that lasted that long
+	At10 *FrameDiff
+	At15 *FrameDiff
+}
+
+//laneOpponent finds who the summoner was laning against, using the same matchups as the match page's graphs.
+//ok is false when Riot couldn't place the summoner, or their position doesn't have exactly one player on each team.
+func laneOpponent(m MatchInfo) (me Participant, them Participant, ok bool) {
+
+	me, ok = m.player()
+	if !ok {
+		return
+	}
+
+	pair, ok := laneMatchups(m.Stats)[me.Position()]
+	if !ok {
+		return
+	}
+
+	if pair[0].ParticipantID == me.ParticipantID {
+		return me, pair[1], true
+	}
+	return me, pair[0], true
+}
+
+//frameAt compares two participants in the timeline frame for the given minute
+func frameAt(tl Timeline, minute int, me int, them int) *FrameDiff {
+
+	if minute >= len(tl.Frames) {
+		return nil
+	}
+
+	f := tl.Frames[minute]
+	a, okA := f.ParticipantFrames[strconv.Itoa(me)]
+	b, okB := f.ParticipantFrames[strconv.Itoa(them)]
+	if !okA || !okB {
+		return nil
+	}
+
+	return &FrameDiff{
+		Gold:  a.TotalGold - b.TotalGold,
+		CS:    (a.MinionsKilled + a.JungleMinionsKilled) - (b.MinionsKilled + b.JungleMinionsKilled),
+		XP:    a.XP - b.XP,
+		Level: a.Level - b.Level,
+	}
+}
+
+//laneDiff builds the head-to-head for one match. tl may be nil if we never got the match's timeline.
+func laneDiff(ctx context.Context, m MatchInfo, tl *Timeline) (LaneDiff, bool) {
+
+	me, them, ok := laneOpponent(m)
+	if !ok {
+		return LaneDiff{}, false
+	}
+
+	opponent, err := championName(them.ChampionID)
+	if err != nil {
+		logFrom(ctx).Warn("champion name lookup failed", "err", err)
+		opponent = "Champion " + strconv.Itoa(them.ChampionID)
+	}
+
+	d := LaneDiff{
+		GameID:   m.GameID,
+		Position: me.Position(),
+		Champion: m.Name,
+		Opponent: opponent,
+		Win:      me.Stats.Win,
+		CS:       me.Derived.CS - them.Derived.CS,
+		Gold:     me.Stats.GoldEarned - them.Stats.GoldEarned,
+		Damage:   me.Stats.TotalDamageDealtToChampions - them.Stats.TotalDamageDealtToChampions,
+		Kills:    me.Stats.Kills - them.Stats.Kills,
+		Level:    me.Stats.ChampLevel - them.Stats.ChampLevel,
+	}
+
+	if tl != nil {
+		d.At10 = frameAt(*tl, 10, me.ParticipantID, them.ParticipantID)
+		d.At15 = frameAt(*tl, 15, me.ParticipantID, them.ParticipantID)
+	}
+
+	return d, true
+}
+
+//MatchupStats adds up every archived game against one opposing champion
+type MatchupStats struct {
+	Opponent string
+	Games    int
+	Wins     int
+
+	CS, Gold, Damage, Kills, Level int
+
+	//Only games with a timeline count towards the 10 and 15 minute averages, so they're kept separately
+	Games10, Gold10, CS10 int
+	Games15, Gold15, CS15 int
+}
+
+func (s MatchupStats) WinRate() float64 {
+	return percent(s.Wins, s.Games)
+}
+
+//average is total / games, or 0 with no games
+func average(total int, games int) float64 {
+	if games == 0 {
+		return 0
+	}
+	return float64(total) / float64(games)
+}
+
+func (s MatchupStats) AvgCS() float64     { return average(s.CS, s.Games) }
+func (s MatchupStats) AvgGold() float64   { return average(s.Gold, s.Games) }
+func (s MatchupStats) AvgDamage() float64 { return average(s.Damage, s.Games) }
+func (s MatchupStats) AvgKills() float64  { return average(s.Kills, s.Games) }
+func (s MatchupStats) AvgLevel() float64  { return average(s.Level, s.Games) }
+func (s MatchupStats) AvgGold10() float64 { return average(s.Gold10, s.Games10) }
+func (s MatchupStats) AvgCS10() float64   { return average(s.CS10, s.Games10) }
+func (s MatchupStats) AvgGold15() float64 { return average(s.Gold15, s.Games15) }
+func (s MatchupStats) AvgCS15() float64   { return average(s.CS15, s.Games15) }
+
+//laneDiffs works out the head-to-head for every archived match where we can tell who the lane opponent was
+func laneDiffs(ctx context.Context, region string, matches []MatchInfo) []LaneDiff {
+
+	var diffs []LaneDiff
+	for _, m := range matches {
+		var tl *Timeline
+		if t, ok, err := loadTimeline(region, m.GameID); err != nil {
+			logFrom(ctx).Warn("timeline failed", "err", err)
+		} else if ok {
+			tl = &t
+		}
+
+		if d, ok := laneDiff(ctx, m, tl); ok {
+			diffs = append(diffs, d)
+		}
+	}
+
+	return diffs
+}
+
+//matchupBreakdown groups lane diffs by the opposing champion, most faced first
+func matchupBreakdown(diffs []LaneDiff) []MatchupStats {
+
+	byOpponent := make(map[string]*MatchupStats)
+	for _, d := range diffs {
+		s, ok := byOpponent[d.Opponent]
+		if !ok {
+			s = &MatchupStats{Opponent: d.Opponent}
+			byOpponent[d.Opponent] = s
+		}
+
+		s.Games++
+		if d.Win {
+			s.Wins++
+		}
+		s.CS += d.CS
+		s.Gold += d.Gold
+		s.Damage += d.Damage
+		s.Kills += d.Kills
+		s.Level += d.Level
+
+		if d.At10 != nil {
+			s.Games10++
+			s.Gold10 += d.At10.Gold
+			s.CS10 += d.At10.CS
+		}
+		if d.At15 != nil {
+			s.Games15++
+			s.Gold15 += d.At15.Gold
+			s.CS15 += d.At15.CS
+		}
+	}
+
+	list := make([]MatchupStats, 0, len(byOpponent))
+	for _, s := range byOpponent {
+		list = append(list, *s)
+	}
+	sort.Slice(list, func(a, b int) bool {
+		if list[a].Games != list[b].Games {
+			return list[a].Games > list[b].Games
+		}
+		return list[a].Opponent < list[b].Opponent
+	})
+
+	return list
+}
+
+//matchupsFunc serves /matchups?account=<id>&name=<name>, how the summoner does against each champion in lane
+func matchupsFunc(response http.ResponseWriter, request *http.Request) {
+
+	accountID, err := strconv.Atoi(request.FormValue("account"))
+	if err != nil {
+		http.Error(response, "account must be a number", http.StatusBadRequest)
+		return
+	}
+
+	matches, err := loadArchive(defaultRegion, accountID)
+	if err != nil {
+		http.Error(response, "Could not read the match archive", http.StatusInternalServerError)
+		return
+	}
+
+	page := struct {
+		SummonerName string
+		Matchups     []MatchupStats
+	}{
+		SummonerName: request.FormValue("name"),
+		Matchups:     matchupBreakdown(laneDiffs(request.Context(), defaultRegion, matches)),
+	}
+
+	renderPage(request.Context(), response, "matchups", page)
+
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+//knownChampions fills in champion names for the test, so championName never asks Riot
+func knownChampions(t *testing.T, names map[int]string) {
+
+	championNames.Lock()
+	old := championNames.byID
+	championNames.byID = names
+	championNames.Unlock()
+
+	t.Cleanup(func() {
+		championNames.Lock()
+		championNames.byID = old
+		championNames.Unlock()
+	})
+}
+
+//laner is a participant in the given lane and role, playing champion
+func laner(id, team, champion int, lane, role string) Participant {
+	var p Participant
+	p.ParticipantID = id
+	p.TeamID = team
+	p.ChampionID = champion
+	p.Timeline.Lane, p.Timeline.Role = lane, role
+	return p
+}
+
+func TestLaneMatchups(t *testing.T) {
+
+	var s MatchStats
+	s.Participants = []Participant{
+		//Red side is listed first, but blue still goes first in the pair
+		laner(6, 200, 2, "TOP", "SOLO"),
+		laner(1, 100, 1, "TOP", "SOLO"),
+		//Two junglers on blue and none on red, so there's no jungle matchup
+		laner(2, 100, 3, "JUNGLE", "NONE"),
+		laner(3, 100, 4, "JUNGLE", "NONE"),
+		//Both mids on the same team isn't a matchup either
+		laner(4, 200, 5, "MID", "SOLO"),
+		laner(5, 200, 6, "MIDDLE", "SOLO"),
+		//Riot couldn't place either of these
+		laner(7, 100, 7, "NONE", "NONE"),
+		laner(8, 200, 8, "NONE", "NONE"),
+	}
+
+	matchups := laneMatchups(s)
+	if len(matchups) != 1 {
+		t.Fatalf("got matchups for %d positions, want only top", len(matchups))
+	}
+	top, ok := matchups["top"]
+	if !ok || top[0].ParticipantID != 1 || top[1].ParticipantID != 6 {
+		t.Errorf("top = %d against %d, want 1 against 6", top[0].ParticipantID, top[1].ParticipantID)
+	}
+}
+
+func TestLaneDiff(t *testing.T) {
+
+	knownChampions(t, map[int]string{1: "Garen", 2: "Darius"})
+
+	m := MatchInfo{GameID: 5, Name: "Darius"}
+	me := laner(6, 200, 2, "TOP", "SOLO")
+	me.Stats.Win = true
+	me.Stats.GoldEarned, me.Stats.Kills, me.Stats.ChampLevel = 12000, 7, 16
+	me.Derived.CS = 220
+	them := laner(1, 100, 1, "TOP", "SOLO")
+	them.Stats.GoldEarned, them.Stats.Kills, them.Stats.ChampLevel = 9000, 2, 14
+	them.Derived.CS = 180
+	m.Stats.Participants = []Participant{them, me}
+	m.Stats.ParticipantID = 6
+
+	//Our summoner is red side, so the opponent comes out of the other half of the pair
+	if gotMe, gotThem, ok := laneOpponent(m); !ok || gotMe.ParticipantID != 6 || gotThem.ParticipantID != 1 {
+		t.Errorf("laneOpponent = %d against %d, %v, want 6 against 1", gotMe.ParticipantID, gotThem.ParticipantID, ok)
+	}
+
+	//Only one frame past 10 minutes, so there's no 15 minute comparison
+	tl := &Timeline{Frames: make([]TimelineFrame, 12)}
+	tl.Frames[10].ParticipantFrames = map[string]ParticipantFrame{
+		"6": {TotalGold: 4000, MinionsKilled: 80, JungleMinionsKilled: 4, XP: 5000, Level: 8},
+		"1": {TotalGold: 3500, MinionsKilled: 70, XP: 4600, Level: 7},
+	}
+
+	d, ok := laneDiff(context.Background(), m, tl)
+	if !ok {
+		t.Fatal("no lane diff")
+	}
+	want := LaneDiff{GameID: 5, Position: "top", Champion: "Darius", Opponent: "Garen", Win: true, CS: 40, Gold: 3000, Kills: 5, Level: 2}
+	at10 := d.At10
+	d.At10, d.At15 = nil, nil
+	if d != want {
+		t.Errorf("got %+v, want %+v", d, want)
+	}
+	if at10 == nil || *at10 != (FrameDiff{Gold: 500, CS: 14, XP: 400, Level: 1}) {
+		t.Errorf("at 10 = %+v, want 500 gold, 14 cs, 400 xp and 1 level ahead", at10)
+	}
+
+	//Without a position nobody is the opponent
+	m.Stats.Participants[1].Timeline.Lane = "NONE"
+	if _, ok := laneDiff(context.Background(), m, nil); ok {
+		t.Error("got a lane diff for a summoner Riot couldn't place")
+	}
+}
+
+func TestMatchupBreakdown(t *testing.T) {
+
+	diffs := []LaneDiff{
+		{Opponent: "Zed", Win: true, CS: 10, At10: &FrameDiff{Gold: 100}},
+		{Opponent: "Zed", Win: false, CS: -30},
+		{Opponent: "Ahri", Win: true, CS: 5},
+		{Opponent: "Lux", Win: false, CS: 0},
+	}
+
+	list := matchupBreakdown(diffs)
+	var order []string
+	for _, s := range list {
+		order = append(order, s.Opponent)
+	}
+	//Most faced first, then by name
+	if len(order) != 3 || order[0] != "Zed" || order[1] != "Ahri" || order[2] != "Lux" {
+		t.Fatalf("got %v, want Zed, Ahri, Lux", order)
+	}
+
+	zed := list[0]
+	if zed.Games != 2 || zed.WinRate() != 50 || zed.AvgCS() != -10 {
+		t.Errorf("zed = %+v, want 2 games, 50%% won and -10 cs", zed)
+	}
+	//Only the game with a timeline counts towards the 10 minute average
+	if zed.Games10 != 1 || zed.AvgGold10() != 100 || zed.AvgGold15() != 0 {
+		t.Errorf("zed at 10 = %d games, %v gold, at 15 %v gold, want 1, 100 and 0", zed.Games10, zed.AvgGold10(), zed.AvgGold15())
+	}
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+//searchBuckets are the upper bounds, in seconds, of the search latency histogram
+var searchBuckets = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+//metrics holds every counter /metrics reports that isn't already counted somewhere else, like the cache's hits and misses
+var metrics = struct {
+	sync.Mutex
+
+	//searches by how they turned out: ok, stale, not_found, cancelled or error
+	searches map[string]int
+
+	//searchCounts[i] is how many searches took at most searchBuckets[i] seconds
+	searchCounts  []int
+	searchSeconds float64
+	searchTotal   int
+
+	//riotCalls by endpoint and status, where a call that never got an answer has the status "error"
+	riotCalls   map[[2]string]int
+	rateLimited map[string]int
+
+	//headroom is the last number of calls left that Riot told us about, by scope (app or method) and endpoint.
+	//The app limit covers every endpoint, so it's kept under the endpoint "all".
+	headroom map[[2]string]int
+}{
+	searches:     make(map[string]int),
+	searchCounts: make([]int, len(searchBuckets)),
+	riotCalls:    make(map[[2]string]int),
+	rateLimited:  make(map[string]int),
+	headroom:     make(map[[2]string]int),
+}
+
+//recordSearch counts one summonerSearch and how long it took
+func recordSearch(result string, took time.Duration) {
+
+	metrics.Lock()
+	defer metrics.Unlock()
+
+	metrics.searches[result]++
+	seconds := took.Seconds()
+	for i, bound := range searchBuckets {
+		if seconds <= bound {
+			metrics.searchCounts[i]++
+		}
+	}
+	metrics.searchSeconds += seconds
+	metrics.searchTotal++
+}
+
+//searchResult sorts a finished search into one of the result labels
+func searchResult(out *Output, err error) string {
+	switch {
+	case err == nil && out.Stale:
+		return "stale"
+	case err == nil:
+		return "ok"
+	case err == errNoSummoner:
+		return "not_found"
+	case isCancelled(err):
+		return "cancelled"
+	}
+	return "error"
+}
+
+//recordUpstream counts one call to Riot. appHeadroom and methodHeadroom are -1 when Riot didn't say.
+func recordUpstream(endpoint string, status string, appHeadroom int, methodHeadroom int) {
+
+	metrics.Lock()
+	defer metrics.Unlock()
+
+	metrics.riotCalls[[2]string{endpoint, status}]++
+	if status == "429" {
+		metrics.rateLimited[endpoint]++
+	}
+	if appHeadroom >= 0 {
+		metrics.headroom[[2]string{"app", "all"}] = appHeadroom
+	}
+	if methodHeadroom >= 0 {
+		metrics.headroom[[2]string{"method", endpoint}] = methodHeadroom
+	}
+}
+
+//inFlight is how many distinct calls to Riot are running right now
+func (g *flightGroup) inFlight() int {
+	g.Lock()
+	defer g.Unlock()
+	return len(g.calls)
+}
+
+//ArchiveSize is what's on disk in the archive, across every region
+type ArchiveSize struct {
+	Accounts  int
+	Matches   int
+	Timelines int
+	Bytes     int64
+}
+
+//archiveSizeTTL is how long one walk of the archive is reused. The walk stats every file, which gets slow as the archive grows,
+//and the numbers only need to be roughly current.
+const archiveSizeTTL = time.Minute
+
+var archiveSizes struct {
+	sync.Mutex
+	size    ArchiveSize
+	counted time.Time
+}
+
+//archiveSize is the archive's size as of the last walk, walking it again if that was more than archiveSizeTTL ago
+func archiveSize() (ArchiveSize, error) {
+
+	archiveSizes.Lock()
+	defer archiveSizes.Unlock()
+
+	if !archiveSizes.counted.IsZero() && time.Since(archiveSizes.counted) < archiveSizeTTL {
+		return archiveSizes.size, nil
+	}
+
+	size, err := walkArchive()
+	if err != nil {
+		return size, err
+	}
+	archiveSizes.size, archiveSizes.counted = size, time.Now()
+
+	return size, nil
+}
+
+//walkArchive counts everything in the archive folder. NA is at the top and every other region is a folder of the same layout.
+func walkArchive() (ArchiveSize, error) {
+
+	var size ArchiveSize
+	err := filepath.WalkDir(archiveDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, _ := filepath.Rel(archiveDir, path)
+		parts := strings.Split(rel, string(filepath.Separator))
+		if _, ok := platforms[parts[0]]; ok && len(parts) > 1 {
+			parts = parts[1:]
+		}
+
+		if d.IsDir() {
+			if len(parts) == 1 && isAccountDir(parts[0]) {
+				size.Accounts++
+			}
+			return nil
+		}
+		if filepath.Ext(path) != ".json" {
+			return nil
+		}
+
+		if info, err := d.Info(); err == nil {
+			size.Bytes += info.Size()
+		}
+		if len(parts) == 2 && parts[0] == "timelines" {
+			size.Timelines++
+		} else if len(parts) == 2 && isAccountDir(parts[0]) {
+			size.Matches++
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return size, err
+	}
+
+	return size, nil
+}
+
+//promWriter writes the Prometheus text format by hand, one metric family at a time
+type promWriter struct {
+	w io.Writer
+}
+
+func (p promWriter) family(name string, kind string, help string) {
+	fmt.Fprintf(p.w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+//sample writes one line. labels go in pairs of name and value.
+func (p promWriter) sample(name string, value float64, labels ...string) {
+
+	var pairs []string
+	for i := 0; i+1 < len(labels); i += 2 {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", labels[i], labels[i+1]))
+	}
+
+	if len(pairs) > 0 {
+		fmt.Fprintf(p.w, "%s{%s} %g\n", name, strings.Join(pairs, ","), value)
+	} else {
+		fmt.Fprintf(p.w, "%s %g\n", name, value)
+	}
+}
+
+//sortedKeys gives map keys in order, so /metrics reads the same from one scrape to the next
+func sortedKeys[K comparable, V any](m map[K]V) []K {
+
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(a, b int) bool { return fmt.Sprint(keys[a]) < fmt.Sprint(keys[b]) })
+
+	return keys
+}
+
+//metricsFunc serves /metrics in the Prometheus text format
+func metricsFunc(response http.ResponseWriter, request *http.Request) {
+
+	//Everything is written to a buffer first, so a slow scraper never holds the metrics lock
+	var buf bytes.Buffer
+	p := promWriter{w: &buf}
+
+	metrics.Lock()
+
+	p.family("ivern_searches_total", "counter", "Summoner searches by result.")
+	for _, result := range sortedKeys(metrics.searches) {
+		p.sample("ivern_searches_total", float64(metrics.searches[result]), "result", result)
+	}
+
+	p.family("ivern_search_duration_seconds", "histogram", "How long summoner searches took.")
+	for i, bound := range searchBuckets {
+		p.sample("ivern_search_duration_seconds_bucket", float64(metrics.searchCounts[i]), "le", fmt.Sprint(bound))
+	}
+	p.sample("ivern_search_duration_seconds_bucket", float64(metrics.searchTotal), "le", "+Inf")
+	p.sample("ivern_search_duration_seconds_sum", metrics.searchSeconds)
+	p.sample("ivern_search_duration_seconds_count", float64(metrics.searchTotal))
+
+	p.family("ivern_riot_requests_total", "counter", "Calls to the Riot API by endpoint and status.")
+	for _, k := range sortedKeys(metrics.riotCalls) {
+		p.sample("ivern_riot_requests_total", float64(metrics.riotCalls[k]), "endpoint", k[0], "status", k[1])
+	}
+
+	p.family("ivern_riot_rate_limited_total", "counter", "Calls to the Riot API answered with 429 Too Many Requests.")
+	for _, endpoint := range sortedKeys(metrics.rateLimited) {
+		p.sample("ivern_riot_rate_limited_total", float64(metrics.rateLimited[endpoint]), "endpoint", endpoint)
+	}
+
+	p.family("ivern_riot_rate_limit_remaining", "gauge", "Calls left in the tightest rate limit window, as of the last answer from Riot.")
+	for _, k := range sortedKeys(metrics.headroom) {
+		p.sample("ivern_riot_rate_limit_remaining", float64(metrics.headroom[k]), "scope", k[0], "endpoint", k[1])
+	}
+
+	metrics.Unlock()
+
+	cache := riotCache.stats()
+	p.family("ivern_cache_requests_total", "counter", "Riot API calls looked up in the response cache, by endpoint and result.")
+	for _, endpoint := range sortedKeys(cache.Counts) {
+		c := cache.Counts[endpoint]
+		p.sample("ivern_cache_requests_total", float64(c.Hits), "endpoint", endpoint, "result", "hit")
+		p.sample("ivern_cache_requests_total", float64(c.DiskHits), "endpoint", endpoint, "result", "disk_hit")
+		p.sample("ivern_cache_requests_total", float64(c.StaleHits), "endpoint", endpoint, "result", "stale_hit")
+		p.sample("ivern_cache_requests_total", float64(c.Misses), "endpoint", endpoint, "result", "miss")
+	}
+	p.family("ivern_cache_hit_ratio", "gauge", "Share of cache lookups answered from memory or disk since startup, from 0 to 1.")
+	p.sample("ivern_cache_hit_ratio", cache.HitRate/100)
+	p.family("ivern_cache_entries", "gauge", "Responses held in the in-memory cache.")
+	p.sample("ivern_cache_entries", float64(cache.Entries))
+	p.family("ivern_cache_bytes", "gauge", "Size of the response bodies held in the in-memory cache.")
+	p.sample("ivern_cache_bytes", float64(cache.Bytes))
+
+	p.family("ivern_riot_coalesced_total", "counter", "Calls that waited on an identical call already in flight.")
+	p.sample("ivern_riot_coalesced_total", float64(riotFlights.shared()))
+	p.family("ivern_riot_in_flight", "gauge", "Distinct calls to Riot waiting for an answer right now.")
+	p.sample("ivern_riot_in_flight", float64(riotFlights.inFlight()))
+
+	breakerOpen := 0.0
+	if riotBreaker.open() {
+		breakerOpen = 1
+	}
+	p.family("ivern_riot_breaker_open", "gauge", "1 while the circuit breaker is refusing calls to Riot.")
+	p.sample("ivern_riot_breaker_open", breakerOpen)
+
+	size, err := archiveSize()
+	if err != nil {
+		logFrom(request.Context()).Warn("archive access failed", "err", err)
+	}
+	p.family("ivern_archive_accounts", "gauge", "Accounts with matches in the archive, counted at most once a minute.")
+	p.sample("ivern_archive_accounts", float64(size.Accounts))
+	p.family("ivern_archive_matches", "gauge", "Match files in the archive. A match is counted once for every archived account that played in it.")
+	p.sample("ivern_archive_matches", float64(size.Matches))
+	p.family("ivern_archive_timelines", "gauge", "Match timelines in the archive.")
+	p.sample("ivern_archive_timelines", float64(size.Timelines))
+	p.family("ivern_archive_bytes", "gauge", "Size of every JSON file in the archive.")
+	p.sample("ivern_archive_bytes", float64(size.Bytes))
+
+	response.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	response.Write(buf.Bytes())
+
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+//A lobby holds five players, so that's as many as one multi-search will look up
+const maxMultiSearch = 5
+
+//The client prints a line like "Name joined the lobby" for everyone in champion select
+var lobbyLine = regexp.MustCompile(`^(.+?) joined the lobby$`)
+
+//parseLobby pulls summoner names out of whatever was pasted: either the client's lobby messages, or plain names
+//separated by commas or new lines. Repeats are dropped (ignoring case, like the client does), and only the first five are kept.
+func parseLobby(text string) []string {
+
+	var lines []string
+	for _, line := range strings.Split(strings.Replace(text, "\r", "", -1), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	var names []string
+	for _, line := range lines {
+		if m := lobbyLine.FindStringSubmatch(line); m != nil {
+			names = append(names, m[1])
+		}
+	}
+
+	//Nothing looked like a lobby message, so treat the text as a list of names
+	if len(names) == 0 {
+		for _, line := range lines {
+			names = append(names, strings.Split(line, ",")...)
+		}
+	}
+
+	seen := make(map[string]bool)
+	var unique []string
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		key := strings.ToLower(name)
+		if name == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		unique = append(unique, name)
+		if len(unique) == maxMultiSearch {
+			break
+		}
+	}
+
+	return unique
+}
+
+//SummonerCard is the compact summary of one summoner on the multi-search page
+type SummonerCard struct {
+	Name          string
+	ProfileIconID int
+	Error         string
+
+	Solo *LeagueEntry
+
+	//Games and WinRate come from the archive, which is what the roles and champions are built from too
+	Games     int
+	WinRate   float64
+	Roles     []PositionStats
+	Champions []ChampionStats
+}
+
+//summonerCard runs a full search for one name and boils it down to a card.
+//A failed search still gets a card, just with the error on it, so one typo doesn't hide the other four players.
+func summonerCard(ctx context.Context, name string) SummonerCard {
+
+	out, err := summonerSearch(ctx, defaultRegion, name)
+	if err == errNoSummoner {
+		return SummonerCard{Name: name, Error: "No Summoner Found"}
+	}
+	if err != nil {
+		logFrom(ctx).Warn("multi-search failed", "err", err)
+		return SummonerCard{Name: name, Error: "Could not look up this summoner"}
+	}
+
+	card := SummonerCard{
+		Name:          out.SummonerName,
+		ProfileIconID: out.ProfileIconID,
+		Solo:          out.Ranked.Solo,
+	}
+
+	wins := 0
+	for _, p := range out.Positions.Positions {
+		card.Games += p.Games
+		wins += p.Wins
+	}
+	card.WinRate = percent(wins, card.Games)
+
+	//Main roles are the two most played, as long as they were actually played more than once
+	roles := append([]PositionStats(nil), out.Positions.Positions...)
+	sort.SliceStable(roles, func(a, b int) bool { return roles[a].Games > roles[b].Games })
+	for _, r := range roles {
+		if len(card.Roles) == 2 || r.Position == "unknown" {
+			continue
+		}
+		if len(card.Roles) == 0 || r.Games > 1 {
+			card.Roles = append(card.Roles, r)
+		}
+	}
+
+	card.Champions = out.Champions.Champions
+	if len(card.Champions) > 3 {
+		card.Champions = card.Champions[:3]
+	}
+
+	return card
+}
+
+//multiSearchFunc serves /multi. A GET shows the empty box, and a POST looks up every name at the same time.
+func multiSearchFunc(response http.ResponseWriter, request *http.Request) {
+
+	page := struct {
+		Text  string
+		Max   int
+		Cards []SummonerCard
+	}{Max: maxMultiSearch}
+
+	if request.Method == "POST" {
+		page.Text = request.FormValue("Lobby")
+		names := parseLobby(page.Text)
+
+		page.Cards = make([]SummonerCard, len(names))
+		var wg sync.WaitGroup
+		for i, name := range names {
+			wg.Add(1)
+			go func(i int, name string) {
+				defer wg.Done()
+				page.Cards[i] = summonerCard(request.Context(), name)
+			}(i, name)
+		}
+		wg.Wait()
+	}
+
+	renderPage(request.Context(), response, "multi", page)
+
+}
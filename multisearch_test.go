@@ -0,0 +1,32 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseLobby(t *testing.T) {
+
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"empty", "  \n\n", nil},
+		{"lobby messages", "Foo joined the lobby\nBar Baz joined the lobby\n", []string{"Foo", "Bar Baz"}},
+		{"windows line endings", "Foo joined the lobby\r\nBar joined the lobby\r\n", []string{"Foo", "Bar"}},
+		//Chat in between is ignored once anything looks like a lobby message
+		{"lobby with chat", "Foo joined the lobby\nFoo: mid or feed\nBar joined the lobby", []string{"Foo", "Bar"}},
+		{"commas", "Foo, Bar Baz ,Qux", []string{"Foo", "Bar Baz", "Qux"}},
+		{"lines and commas", "Foo\nBar, Qux\n", []string{"Foo", "Bar", "Qux"}},
+		{"repeats ignore case", "Foo\nfoo\nFOO joined\nBar", []string{"Foo", "FOO joined", "Bar"}},
+		{"empty names", "Foo,,\n, ,Bar", []string{"Foo", "Bar"}},
+		{"only five", "A,B,C,D,E,F,G", []string{"A", "B", "C", "D", "E"}},
+	}
+
+	for _, tt := range tests {
+		if got := parseLobby(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: parseLobby(%q) = %q, want %q", tt.name, tt.text, got, tt.want)
+		}
+	}
+}
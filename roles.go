@@ -0,0 +1,202 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"strings"
+	"time"
+)
+
+//The five positions we sort every game into, in the order they're shown on the page.
+//Games Riot couldn't place (AFK laners, lane swaps it got confused by) end up as "unknown", which always goes last.
+var positions = []string{"top", "jungle", "mid", "adc", "support", "unknown"}
+
+//Colors for each position in the role share chart
+var positionColors = map[string]string{
+	"top":     "#E57373",
+	"jungle":  "#81C784",
+	"mid":     "#64B5F6",
+	"adc":     "#FFD54F",
+	"support": "#BA68C8",
+	"unknown": "#9E9E9E",
+}
+
+//normalizePosition turns Riot's lane and role pair into one of our five positions.
+//Riot guesses these from where a player spent the early game, so the bottom lane is only told apart by the DUO_ roles.
+func normalizePosition(lane string, role string) string {
+
+	switch lane {
+	case "TOP":
+		return "top"
+	case "JUNGLE":
+		return "jungle"
+	case "MID", "MIDDLE":
+		return "mid"
+	}
+
+	switch role {
+	case "DUO_CARRY":
+		return "adc"
+	case "DUO_SUPPORT":
+		return "support"
+	}
+
+	//A lone player in the bottom lane is almost always the carry
+	if (lane == "BOTTOM" || lane == "BOT") && role == "SOLO" {
+		return "adc"
+	}
+
+	return "unknown"
+}
+
+//Position is the normalized position the summoner played in this match, taken from the matchlist's lane and role
+func (m MatchInfo) Position() string {
+	return normalizePosition(m.Lane, m.Role)
+}
+
+//Position is where Riot thinks this participant played, for any of the ten players in a match
+func (p Participant) Position() string {
+	return normalizePosition(p.Timeline.Lane, p.Timeline.Role)
+}
+
+//PositionStats is one row of the position breakdown
+type PositionStats struct {
+	Position string
+	Color    string
+	Games    int
+	Wins     int
+	Share    float64
+}
+
+func (p PositionStats) WinRate() float64 {
+	if p.Games == 0 {
+		return 0
+	}
+	return 100 * float64(p.Wins) / float64(p.Games)
+}
+
+//PositionBreakdown is what the profile shows about roles: the totals, and the chart of how they changed over time
+type PositionBreakdown struct {
+	Positions []PositionStats
+	Chart     template.HTML
+}
+
+//positionBreakdown counts games and wins per position across a summoner's archived matches.
+//Positions that were never played are left out.
+func positionBreakdown(matches []MatchInfo) PositionBreakdown {
+
+	counts := make(map[string]*PositionStats)
+	total := 0
+
+	for _, m := range matches {
+		p, ok := m.player()
+		if !ok {
+			continue
+		}
+
+		pos := m.Position()
+		s, ok := counts[pos]
+		if !ok {
+			s = &PositionStats{Position: pos, Color: positionColors[pos]}
+			counts[pos] = s
+		}
+
+		s.Games++
+		if p.Stats.Win {
+			s.Wins++
+		}
+		total++
+	}
+
+	var breakdown PositionBreakdown
+	for _, pos := range positions {
+		s, ok := counts[pos]
+		if !ok {
+			continue
+		}
+		s.Share = 100 * float64(s.Games) / float64(total)
+		breakdown.Positions = append(breakdown.Positions, *s)
+	}
+
+	breakdown.Chart = roleShareChart(matches, 12)
+
+	return breakdown
+}
+
+//roleShareChart draws a stacked bar per week, for the last `weeks` weeks that have any games in them,
+//where each bar is split by how much of that week was spent in each position.
+//It's plain SVG built on the server, so the page doesn't need any JavaScript to show it.
+func roleShareChart(matches []MatchInfo, weeks int) template.HTML {
+
+	type week struct {
+		start  time.Time
+		counts map[string]int
+		total  int
+	}
+
+	var buckets []*week
+	byStart := make(map[time.Time]*week)
+
+	for _, m := range matches {
+		if _, ok := m.player(); !ok {
+			continue
+		}
+
+		//Weeks start on Monday
+		t := time.Unix(m.Timestamp/1000, 0).UTC()
+		start := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		start = start.AddDate(0, 0, -((int(start.Weekday()) + 6) % 7))
+
+		w, ok := byStart[start]
+		if !ok {
+			w = &week{start: start, counts: make(map[string]int)}
+			byStart[start] = w
+			buckets = append(buckets, w)
+		}
+		w.counts[m.Position()]++
+		w.total++
+	}
+
+	if len(buckets) == 0 {
+		return ""
+	}
+
+	//Matches arrive newest first, so the buckets do too. Keep the newest few, then flip them so time reads left to right.
+	if len(buckets) > weeks {
+		buckets = buckets[:weeks]
+	}
+	for i, j := 0, len(buckets)-1; i < j; i, j = i+1, j-1 {
+		buckets[i], buckets[j] = buckets[j], buckets[i]
+	}
+
+	const barWidth, gap, height, labelHeight = 40, 10, 200, 20
+
+	var svg strings.Builder
+	width := len(buckets) * (barWidth + gap)
+	fmt.Fprintf(&svg, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" font-family="sans-serif" font-size="10">`, width, height+labelHeight)
+
+	for i, w := range buckets {
+		x := i*(barWidth+gap) + gap/2
+		y := float64(height)
+
+		//Stack from the bottom up, in the same order as the breakdown table
+		for _, pos := range positions {
+			n := w.counts[pos]
+			if n == 0 {
+				continue
+			}
+			h := float64(height) * float64(n) / float64(w.total)
+			y -= h
+			fmt.Fprintf(&svg, `<rect x="%d" y="%.1f" width="%d" height="%.1f" fill="%s"><title>%s: %d of %d</title></rect>`,
+				x, y, barWidth, h, positionColors[pos], pos, n, w.total)
+		}
+
+		fmt.Fprintf(&svg, `<text x="%d" y="%d" fill="#FFFFFF" text-anchor="middle">%s</text>`,
+			x+barWidth/2, height+labelHeight-5, w.start.Format("Jan 2"))
+	}
+
+	svg.WriteString(`</svg>`)
+
+	//Everything written above is either a number, a date or one of our own position names, so it's safe to hand to the template as-is
+	return template.HTML(svg.String())
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNormalizePosition(t *testing.T) {
+
+	tests := []struct {
+		lane, role string
+		want       string
+	}{
+		{"TOP", "SOLO", "top"},
+		{"TOP", "DUO_CARRY", "top"},
+		{"JUNGLE", "NONE", "jungle"},
+		{"MID", "SOLO", "mid"},
+		{"MIDDLE", "SOLO", "mid"},
+		{"BOTTOM", "DUO_CARRY", "adc"},
+		{"BOTTOM", "DUO_SUPPORT", "support"},
+		{"BOT", "DUO_SUPPORT", "support"},
+		//The duo roles decide, even when Riot put the lane somewhere odd
+		{"NONE", "DUO_SUPPORT", "support"},
+		{"BOTTOM", "SOLO", "adc"},
+		{"BOT", "SOLO", "adc"},
+		{"BOTTOM", "DUO", "unknown"},
+		{"NONE", "SOLO", "unknown"},
+		{"", "", "unknown"},
+	}
+
+	for _, tt := range tests {
+		if got := normalizePosition(tt.lane, tt.role); got != tt.want {
+			t.Errorf("normalizePosition(%q, %q) = %q, want %q", tt.lane, tt.role, got, tt.want)
+		}
+	}
+}
+
+func TestPositionBreakdown(t *testing.T) {
+
+	at := func(lane, role string, win bool) MatchInfo {
+		m := played(1, "Annie", time.Date(2018, 3, 5, 12, 0, 0, 0, time.UTC).Unix()*1000, win, 0, 0, 0)
+		m.Lane, m.Role = lane, role
+		return m
+	}
+	matches := []MatchInfo{
+		at("MID", "SOLO", true),
+		at("MID", "SOLO", false),
+		at("BOTTOM", "DUO_SUPPORT", true),
+		at("NONE", "NONE", true),
+	}
+
+	b := positionBreakdown(matches)
+
+	//Positions come in the page's order, with unplayed ones left out and unknown last
+	want := []PositionStats{
+		{Position: "mid", Color: positionColors["mid"], Games: 2, Wins: 1, Share: 50},
+		{Position: "support", Color: positionColors["support"], Games: 1, Wins: 1, Share: 25},
+		{Position: "unknown", Color: positionColors["unknown"], Games: 1, Wins: 1, Share: 25},
+	}
+	if len(b.Positions) != len(want) {
+		t.Fatalf("got %+v, want %+v", b.Positions, want)
+	}
+	for i := range want {
+		if b.Positions[i] != want[i] {
+			t.Errorf("got %+v, want %+v", b.Positions[i], want[i])
+		}
+	}
+
+	if !strings.Contains(string(b.Chart), "mid: 2 of 4") {
+		t.Errorf("chart doesn't show the week's mid games: %s", b.Chart)
+	}
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+//The server's timeouts. Writes get a long time since one search can be a dozen calls to Riot, each with retries.
+const (
+	readHeaderTimeout = 5 * time.Second
+	readTimeout       = 10 * time.Second
+	writeTimeout      = 60 * time.Second
+	idleTimeout       = 2 * time.Minute
+
+	//requestTimeout is how long a request gets before its context is cancelled, which stops any calls to Riot it still has left.
+	//It's under writeTimeout so there's still time to send the error page, or the stale data, to whoever is waiting.
+	requestTimeout = 45 * time.Second
+
+	//drainTimeout is how long searches that are already running get to finish once we're told to stop.
+	//It's longer than requestTimeout, so any search still running gets to hit its own deadline and answer.
+	drainTimeout = requestTimeout + 5*time.Second
+)
+
+//withDeadline cancels a request's context after requestTimeout. Without it a search carries on calling Riot
+//long after the write timeout has cut its visitor off, spending rate limit on an answer nobody gets.
+func withDeadline(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		ctx, cancel := context.WithTimeout(request.Context(), requestTimeout)
+		defer cancel()
+		next.ServeHTTP(response, request.WithContext(ctx))
+	})
+}
+
+//shuttingDown is set as soon as we're told to stop, so /readyz sends new traffic elsewhere while the last searches finish
+var shuttingDown atomic.Bool
+
+//serverConfig is how the website is served. With no certificate it's plain HTTP on Addr.
+//With CertFile and KeyFile it's HTTPS on Addr instead, and if RedirectAddr is set, plain HTTP there just sends people to HTTPS.
+type serverConfig struct {
+	Addr         string
+	CertFile     string
+	KeyFile      string
+	RedirectAddr string
+
+	//Templates is a folder of templates to use instead of the built in ones, see loadTemplates
+	Templates string
+}
+
+//runServer serves handler until SIGINT or SIGTERM, then stops taking new connections, waits for the requests
+//already running and stops the background work. Getting told to stop isn't an error, failing to listen is.
+func runServer(config serverConfig, handler http.Handler) error {
+
+	if (config.CertFile == "") != (config.KeyFile == "") {
+		return errors.New("HTTPS needs both a certificate and a key")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	//Background work gets its own context, since it has to keep going while requests drain
+	work, stopWork := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+	startWorker := func(f func(context.Context)) {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			f(work)
+		}()
+	}
+	defer func() {
+		stopWork()
+		workers.Wait()
+	}()
+
+	site := &http.Server{
+		Addr:              config.Addr,
+		Handler:           handler,
+		ReadHeaderTimeout: readHeaderTimeout,
+		ReadTimeout:       readTimeout,
+		WriteTimeout:      writeTimeout,
+		IdleTimeout:       idleTimeout,
+	}
+	servers := []*http.Server{site}
+
+	if config.CertFile != "" {
+		certs, err := newCertReloader(config.CertFile, config.KeyFile)
+		if err != nil {
+			return err
+		}
+		site.TLSConfig = &tls.Config{GetCertificate: certs.getCertificate, MinVersion: tls.VersionTLS12}
+		site.Handler = withHSTS(handler)
+		startWorker(certs.watch)
+
+		if config.RedirectAddr != "" {
+			servers = append(servers, &http.Server{
+				Addr:              config.RedirectAddr,
+				Handler:           redirectToHTTPS(config.Addr),
+				ReadHeaderTimeout: readHeaderTimeout,
+				ReadTimeout:       readTimeout,
+				WriteTimeout:      readTimeout,
+				IdleTimeout:       idleTimeout,
+			})
+		}
+	}
+
+	//While Riot is down, this is what notices it's back
+	startWorker(func(ctx context.Context) { riotBreaker.probeLoop(ctx, probeRiot) })
+	startWorker(func(ctx context.Context) { tracer.flushLoop(ctx, 5*time.Second) })
+	startWorker(warmStaticData)
+
+	failed := make(chan error, len(servers))
+	for _, server := range servers {
+		go func(server *http.Server) {
+			if server.TLSConfig != nil {
+				slog.Info("serving", "addr", server.Addr, "https", true)
+				//The certificate comes from GetCertificate, so there are no files to pass here
+				failed <- server.ListenAndServeTLS("", "")
+				return
+			}
+			slog.Info("serving", "addr", server.Addr)
+			failed <- server.ListenAndServe()
+		}(server)
+	}
+
+	//If any server can't listen, the rest are stopped the same way a signal would
+	var err error
+	running := len(servers)
+	select {
+	case err = <-failed:
+		running--
+	case <-ctx.Done():
+	}
+
+	shuttingDown.Store(true)
+	slog.Info("shutting down, waiting for running requests", "timeout", drainTimeout.String())
+
+	drain, cancel := context.WithTimeout(context.Background(), drainTimeout)
+	defer cancel()
+	for _, server := range servers {
+		if shutdownErr := server.Shutdown(drain); shutdownErr != nil {
+			//Whatever is still going after the timeout gets cut off
+			slog.Warn("requests were still running at shutdown", "addr", server.Addr, "err", shutdownErr)
+			server.Close()
+		}
+	}
+
+	for ; running > 0; running-- {
+		if e := <-failed; !errors.Is(e, http.ErrServerClosed) && err == nil {
+			err = e
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	slog.Info("stopped")
+	return nil
+}
+
+//warmStaticData loads the champion list as soon as we start, instead of on the first search, and keeps trying until it gets it
+func warmStaticData(ctx context.Context) {
+
+	for {
+		err := loadChampionNames(ctx)
+		if err == nil {
+			return
+		}
+		slog.Warn("loading static data failed", "err", err)
+
+		select {
+		case <-time.After(riotBreaker.cooldown):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+//apiKeyRejected is set whenever Riot turns our API key down with a 401 or 403, and cleared by the next call it accepts
+var apiKeyRejected atomic.Bool
+
+//noteKeyStatus keeps apiKeyRejected up to date from the status of every answer Riot sends us
+func noteKeyStatus(status int) {
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		apiKeyRejected.Store(true)
+	case status/100 == 2:
+		apiKeyRejected.Store(false)
+	}
+}
+
+//archiveWritable checks the archive the same way a sync would use it, by writing a file into it and removing it again
+func archiveWritable() error {
+
+	if err := os.MkdirAll(archiveDir, 0755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(archiveDir, ".readyz-*")
+	if err != nil {
+		return err
+	}
+	f.Close()
+
+	return os.Remove(f.Name())
+}
+
+//healthzFunc serves /healthz. If we can answer at all we're alive, so it never fails.
+func healthzFunc(response http.ResponseWriter, request *http.Request) {
+
+	response.Header().Set("Content-Type", "text/plain")
+	response.Write([]byte("ok\n"))
+
+}
+
+//readyzFunc serves /readyz, which says whether we should be sent searches right now.
+//Every check is listed with "ok" or what's wrong with it, and any failure makes the whole answer a 503.
+func readyzFunc(response http.ResponseWriter, request *http.Request) {
+
+	checks := map[string]string{
+		"static_data": "ok",
+		"archive":     "ok",
+		"api_key":     "ok",
+		"shutdown":    "ok",
+	}
+
+	if !championsLoaded() {
+		checks["static_data"] = "champion list not loaded yet"
+	}
+	if err := archiveWritable(); err != nil {
+		checks["archive"] = "cannot write to " + filepath.Clean(archiveDir) + ": " + err.Error()
+	}
+	if apiKeyRejected.Load() {
+		checks["api_key"] = "riot rejected the api key"
+	}
+	if shuttingDown.Load() {
+		checks["shutdown"] = "shutting down"
+	}
+
+	status := http.StatusOK
+	for _, result := range checks {
+		if result != "ok" {
+			status = http.StatusServiceUnavailable
+		}
+	}
+
+	response.Header().Set("Content-Type", "application/json")
+	response.WriteHeader(status)
+	json.NewEncoder(response).Encode(map[string]interface{}{
+		"ready":  status == http.StatusOK,
+		"checks": checks,
+	})
+
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWithDeadline(t *testing.T) {
+
+	//A search has to give up, and still have time to answer, before the server stops letting it write
+	if requestTimeout >= writeTimeout {
+		t.Errorf("requestTimeout %v isn't under writeTimeout %v", requestTimeout, writeTimeout)
+	}
+	if drainTimeout <= requestTimeout {
+		t.Errorf("drainTimeout %v won't wait out a search that takes requestTimeout %v", drainTimeout, requestTimeout)
+	}
+
+	var left time.Duration
+	var ok bool
+	handler := withDeadline(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		var deadline time.Time
+		deadline, ok = request.Context().Deadline()
+		left = time.Until(deadline)
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/search?name=x", nil))
+	if !ok {
+		t.Fatal("request has no deadline")
+	}
+	if left > requestTimeout || left < requestTimeout-time.Second {
+		t.Errorf("request got %v, want %v", left, requestTimeout)
+	}
+}
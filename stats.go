@@ -0,0 +1,73 @@
+package main
+
+//DerivedStats are the numbers we work out ourselves from what Riot sends, instead of reading them straight off the match.
+//Rates are per minute of game time, and shares/participation are percentages of the player's own team.
+type DerivedStats struct {
+	CS                int     `json:"cs"`
+	CSPerMin          float64 `json:"csPerMin"`
+	GoldPerMin        float64 `json:"goldPerMin"`
+	DamagePerMin      float64 `json:"damagePerMin"`
+	VisionPerMin      float64 `json:"visionPerMin"`
+	KillParticipation float64 `json:"killParticipation"`
+	DamageShare       float64 `json:"damageShare"`
+	GoldShare         float64 `json:"goldShare"`
+	DamageTakenShare  float64 `json:"damageTakenShare"`
+}
+
+//teamTotals are the sums we need to turn a single player's numbers into shares of their team
+type teamTotals struct {
+	kills       int
+	damage      int
+	gold        int
+	damageTaken int
+}
+
+//derive fills in Derived for every participant in the match.
+//It only reads the decoded stats, so it's safe to run again on a match that's already been derived, like one loaded from the archive.
+func (s *MatchStats) derive() {
+
+	totals := make(map[int]*teamTotals)
+	for _, p := range s.Participants {
+		t, ok := totals[p.TeamID]
+		if !ok {
+			t = &teamTotals{}
+			totals[p.TeamID] = t
+		}
+		t.kills += p.Stats.Kills
+		t.damage += p.Stats.TotalDamageDealtToChampions
+		t.gold += p.Stats.GoldEarned
+		t.damageTaken += p.Stats.TotalDamageTaken
+	}
+
+	minutes := float64(s.GameDuration) / 60
+
+	for i := range s.Participants {
+		p := &s.Participants[i]
+		t := totals[p.TeamID]
+
+		//TotalMinionsKilled only counts lane minions. The creep score the game shows also includes jungle monsters.
+		d := DerivedStats{CS: p.Stats.TotalMinionsKilled + p.Stats.NeutralMinionsKilled}
+
+		if minutes > 0 {
+			d.CSPerMin = float64(d.CS) / minutes
+			d.GoldPerMin = float64(p.Stats.GoldEarned) / minutes
+			d.DamagePerMin = float64(p.Stats.TotalDamageDealtToChampions) / minutes
+			d.VisionPerMin = float64(p.Stats.VisionScore) / minutes
+		}
+
+		d.KillParticipation = percent(p.Stats.Kills+p.Stats.Assists, t.kills)
+		d.DamageShare = percent(p.Stats.TotalDamageDealtToChampions, t.damage)
+		d.GoldShare = percent(p.Stats.GoldEarned, t.gold)
+		d.DamageTakenShare = percent(p.Stats.TotalDamageTaken, t.damageTaken)
+
+		p.Derived = d
+	}
+}
+
+//percent is part out of whole as a percentage, or 0 when there's nothing to divide by
+func percent(part int, whole int) float64 {
+	if whole == 0 {
+		return 0
+	}
+	return 100 * float64(part) / float64(whole)
+}
@@ -0,0 +1,71 @@
+package main
+
+import "testing"
+
+func TestDerive(t *testing.T) {
+
+	stat := func(team, kills, assists, damage, gold, taken, minions, monsters, vision int) Participant {
+		var p Participant
+		p.TeamID = team
+		p.Stats.Kills, p.Stats.Assists = kills, assists
+		p.Stats.TotalDamageDealtToChampions = damage
+		p.Stats.GoldEarned = gold
+		p.Stats.TotalDamageTaken = taken
+		p.Stats.TotalMinionsKilled, p.Stats.NeutralMinionsKilled = minions, monsters
+		p.Stats.VisionScore = vision
+		return p
+	}
+
+	var s MatchStats
+	s.GameDuration = 1200
+	s.Participants = []Participant{
+		stat(100, 6, 4, 15000, 10000, 20000, 180, 20, 30),
+		stat(100, 4, 2, 5000, 6000, 5000, 0, 120, 10),
+		//The other team's numbers don't count towards the first team's shares
+		stat(200, 20, 0, 90000, 30000, 1000, 300, 0, 5),
+		//A player who did nothing on a team that did nothing
+		stat(300, 0, 0, 0, 0, 0, 0, 0, 0),
+	}
+
+	s.derive()
+
+	tests := []struct {
+		name string
+		got  float64
+		want float64
+	}{
+		{"cs", float64(s.Participants[0].Derived.CS), 200},
+		{"jungle cs", float64(s.Participants[1].Derived.CS), 120},
+		{"cs per minute", s.Participants[0].Derived.CSPerMin, 10},
+		{"gold per minute", s.Participants[0].Derived.GoldPerMin, 500},
+		{"damage per minute", s.Participants[0].Derived.DamagePerMin, 750},
+		{"vision per minute", s.Participants[0].Derived.VisionPerMin, 1.5},
+		{"kill participation", s.Participants[0].Derived.KillParticipation, 100},
+		{"assists count toward kill participation", s.Participants[1].Derived.KillParticipation, 60},
+		{"damage share", s.Participants[0].Derived.DamageShare, 75},
+		{"gold share", s.Participants[1].Derived.GoldShare, 37.5},
+		{"damage taken share", s.Participants[0].Derived.DamageTakenShare, 80},
+		{"whole team", s.Participants[2].Derived.DamageShare, 100},
+		{"nothing to share", s.Participants[3].Derived.KillParticipation, 0},
+	}
+
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+
+	//Deriving again, like for a match loaded from the archive, changes nothing
+	before := s.Participants[0].Derived
+	s.derive()
+	if s.Participants[0].Derived != before {
+		t.Errorf("second derive gave %+v, want %+v", s.Participants[0].Derived, before)
+	}
+
+	//A game with no length (a remake that never got going) has no per minute numbers
+	s.GameDuration = 0
+	s.derive()
+	if d := s.Participants[0].Derived; d.CSPerMin != 0 || d.CS != 200 {
+		t.Errorf("zero length game gave %+v", d)
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+)
+
+//DuoPair is one combination of champions the summoner and a teammate played together
+type DuoPair struct {
+	Mine   string
+	Theirs string
+	Games  int
+	Wins   int
+}
+
+func (d DuoPair) WinRate() float64 {
+	return percent(d.Wins, d.Games)
+}
+
+//Teammate is someone who ended up on the summoner's team in more than one archived game
+type Teammate struct {
+	SummonerID   int
+	SummonerName string
+	Games        int
+	Wins         int
+
+	//ApartGames and ApartWins are the summoner's games without this teammate, to compare against
+	ApartGames int
+	ApartWins  int
+
+	Pairs []DuoPair
+}
+
+func (t Teammate) WinRate() float64 {
+	return percent(t.Wins, t.Games)
+}
+
+func (t Teammate) ApartWinRate() float64 {
+	return percent(t.ApartWins, t.ApartGames)
+}
+
+//teammates goes through a summoner's archived games and counts everyone who was on their team.
+//Solo queue puts random people with you all the time, so only those seen at least minGames times are kept.
+func teammates(matches []MatchInfo, minGames int) []Teammate {
+
+	type pairKey struct{ mine, theirs int }
+
+	byID := make(map[int]*Teammate)
+	pairs := make(map[int]map[pairKey]*DuoPair)
+	totalGames, totalWins := 0, 0
+
+	for _, m := range matches {
+		me, ok := m.player()
+		if !ok {
+			continue
+		}
+		totalGames++
+		if me.Stats.Win {
+			totalWins++
+		}
+
+		for _, id := range m.Stats.ParticipantIdentities {
+			if id.ParticipantID == me.ParticipantID {
+				continue
+			}
+
+			them, ok := m.as(id.Player.SummonerID)
+			if !ok {
+				continue
+			}
+			p, _ := them.player()
+			if p.TeamID != me.TeamID {
+				continue
+			}
+
+			t, ok := byID[id.Player.SummonerID]
+			if !ok {
+				t = &Teammate{SummonerID: id.Player.SummonerID}
+				byID[id.Player.SummonerID] = t
+				pairs[id.Player.SummonerID] = make(map[pairKey]*DuoPair)
+			}
+
+			//Matches are newest first, so the first name we see is the one they use now
+			if t.SummonerName == "" {
+				t.SummonerName = id.Player.SummonerName
+			}
+
+			t.Games++
+			if me.Stats.Win {
+				t.Wins++
+			}
+
+			key := pairKey{m.Champion, them.Champion}
+			d, ok := pairs[id.Player.SummonerID][key]
+			if !ok {
+				d = &DuoPair{Mine: m.Name, Theirs: them.Name}
+				pairs[id.Player.SummonerID][key] = d
+			}
+			d.Games++
+			if me.Stats.Win {
+				d.Wins++
+			}
+		}
+	}
+
+	var list []Teammate
+	for id, t := range byID {
+		if t.Games < minGames {
+			continue
+		}
+
+		t.ApartGames = totalGames - t.Games
+		t.ApartWins = totalWins - t.Wins
+
+		for _, d := range pairs[id] {
+			t.Pairs = append(t.Pairs, *d)
+		}
+
+		//Best pairings first: win rate, but only once a pair has been played more than once, then most played
+		sort.Slice(t.Pairs, func(a, b int) bool {
+			pa, pb := t.Pairs[a], t.Pairs[b]
+			if (pa.Games > 1) != (pb.Games > 1) {
+				return pa.Games > 1
+			}
+			if pa.WinRate() != pb.WinRate() {
+				return pa.WinRate() > pb.WinRate()
+			}
+			if pa.Games != pb.Games {
+				return pa.Games > pb.Games
+			}
+			return pa.Mine+pa.Theirs < pb.Mine+pb.Theirs
+		})
+		if len(t.Pairs) > 3 {
+			t.Pairs = t.Pairs[:3]
+		}
+
+		list = append(list, *t)
+	}
+
+	sort.Slice(list, func(a, b int) bool {
+		if list[a].Games != list[b].Games {
+			return list[a].Games > list[b].Games
+		}
+		return list[a].SummonerName < list[b].SummonerName
+	})
+
+	return list
+}
+
+//teammatesFunc serves /teammates?account=<id>&name=<name>&min=<games>, straight from the archive
+func teammatesFunc(response http.ResponseWriter, request *http.Request) {
+
+	accountID, err := strconv.Atoi(request.FormValue("account"))
+	if err != nil {
+		http.Error(response, "account must be a number", http.StatusBadRequest)
+		return
+	}
+
+	minGames, err := strconv.Atoi(request.FormValue("min"))
+	if err != nil || minGames < 1 {
+		minGames = 2
+	}
+
+	matches, err := loadArchive(defaultRegion, accountID)
+	if err != nil {
+		http.Error(response, "Could not read the match archive", http.StatusInternalServerError)
+		return
+	}
+
+	page := struct {
+		SummonerName string
+		AccountID    int
+		MinGames     int
+		Teammates    []Teammate
+	}{
+		SummonerName: request.FormValue("name"),
+		AccountID:    accountID,
+		MinGames:     minGames,
+		Teammates:    teammates(matches, minGames),
+	}
+
+	renderPage(request.Context(), response, "teammates", page)
+
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"embed"
+	"html/template"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+//The pages are built into the binary. Every page in templates/pages is drawn inside templates/layout.html,
+//and can use any of the pieces in templates/partials.
+//
+//go:embed templates
+var embeddedTemplates embed.FS
+
+//pages holds every page, parsed once at startup by loadTemplates and named after its file, like "search" for pages/search.html
+var pages map[string]*template.Template
+
+//overlayFS reads files from override when it has them and from base otherwise, so a restyle only needs the files it changes
+type overlayFS struct {
+	override fs.FS
+	base     fs.FS
+}
+
+func (o overlayFS) Open(name string) (fs.File, error) {
+	if f, err := o.override.Open(name); err == nil {
+		return f, nil
+	}
+	return o.base.Open(name)
+}
+
+//Glob lists the files from both, which is what template.ParseFS uses to find pages and partials
+func (o overlayFS) Glob(pattern string) ([]string, error) {
+
+	seen := make(map[string]bool)
+	for _, fsys := range []fs.FS{o.override, o.base} {
+		names, err := fs.Glob(fsys, pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			seen[name] = true
+		}
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+//loadTemplates parses every page. If overrideDir isn't empty, any file in it with the same path as one of ours
+//(say, overrideDir/layout.html or overrideDir/pages/search.html) is used instead, so pages can be restyled without recompiling.
+func loadTemplates(overrideDir string) error {
+
+	fsys, err := fs.Sub(embeddedTemplates, "templates")
+	if err != nil {
+		return err
+	}
+	if overrideDir != "" {
+		fsys = overlayFS{override: os.DirFS(overrideDir), base: fsys}
+	}
+
+	files, err := fs.Glob(fsys, "pages/*.html")
+	if err != nil {
+		return err
+	}
+
+	loaded := make(map[string]*template.Template)
+	for _, file := range files {
+		name := strings.TrimSuffix(path.Base(file), ".html")
+
+		//Each page is its own set, since they all define "title" and "content"
+		t := template.Must(template.New(name).Parse(`{{template "layout" .}}`))
+		if _, err := t.ParseFS(fsys, "layout.html", "partials/*.html", file); err != nil {
+			return err
+		}
+		loaded[name] = t
+	}
+
+	pages = loaded
+	return nil
+}
+
+//renderPage draws one of the pages loadTemplates parsed
+func renderPage(ctx context.Context, w io.Writer, name string, data interface{}) {
+
+	t, ok := pages[name]
+	if !ok {
+		logFrom(ctx).Error("rendering page failed", "template", name, "err", "no such page")
+		return
+	}
+
+	render(ctx, t, w, data)
+}
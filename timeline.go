@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+//Timeline is the minute-by-minute record of a match. We only keep the participant frames, which is where gold and XP live.
+type Timeline struct {
+	FrameInterval int64           `json:"frameInterval"`
+	Frames        []TimelineFrame `json:"frames"`
+}
+
+type TimelineFrame struct {
+	Timestamp int64 `json:"timestamp"`
+
+	//Riot keys these by the participant ID as a string, "1" through "10"
+	ParticipantFrames map[string]ParticipantFrame `json:"participantFrames"`
+}
+
+type ParticipantFrame struct {
+	ParticipantID       int `json:"participantId"`
+	CurrentGold         int `json:"currentGold"`
+	TotalGold           int `json:"totalGold"`
+	Level               int `json:"level"`
+	XP                  int `json:"xp"`
+	MinionsKilled       int `json:"minionsKilled"`
+	JungleMinionsKilled int `json:"jungleMinionsKilled"`
+}
+
+//DiffGraph is one rendered graph on the match page
+type DiffGraph struct {
+	Title string
+	Chart template.HTML
+}
+
+//Lane matchups are only drawn for positions where each team has exactly one player, otherwise there's nobody obvious to compare against
+func laneMatchups(s MatchStats) map[string][2]Participant {
+
+	byPosition := make(map[string][]Participant)
+	for _, p := range s.Participants {
+		pos := p.Position()
+		byPosition[pos] = append(byPosition[pos], p)
+	}
+
+	matchups := make(map[string][2]Participant)
+	for _, pos := range positions {
+		players := byPosition[pos]
+		if pos == "unknown" || len(players) != 2 || players[0].TeamID == players[1].TeamID {
+			continue
+		}
+
+		//Blue side (team 100) always goes first, so a positive difference means blue is ahead
+		if players[0].TeamID != 100 {
+			players[0], players[1] = players[1], players[0]
+		}
+		matchups[pos] = [2]Participant{players[0], players[1]}
+	}
+
+	return matchups
+}
+
+//frameDiff is, for every frame, value(blue) - value(red), where value adds up whatever we care about over the given participants
+func frameDiff(tl Timeline, blue []int, red []int, value func(ParticipantFrame) int) []float64 {
+
+	diff := make([]float64, len(tl.Frames))
+	for i, f := range tl.Frames {
+		for _, id := range blue {
+			diff[i] += float64(value(f.ParticipantFrames[strconv.Itoa(id)]))
+		}
+		for _, id := range red {
+			diff[i] -= float64(value(f.ParticipantFrames[strconv.Itoa(id)]))
+		}
+	}
+
+	return diff
+}
+
+//diffChart draws a difference over time as an SVG line: above the middle line blue side is ahead, below it red side is.
+//Frames are one minute apart, so the x axis is minutes.
+func diffChart(values []float64) template.HTML {
+
+	const width, height, pad = 600, 160, 30
+
+	if len(values) < 2 {
+		return ""
+	}
+
+	//Scale so the biggest lead either way just touches the edge, and keep it symmetric so zero is always in the middle
+	limit := 1.0
+	for _, v := range values {
+		limit = math.Max(limit, math.Abs(v))
+	}
+
+	x := func(i int) float64 { return pad + float64(i)*float64(width-2*pad)/float64(len(values)-1) }
+	y := func(v float64) float64 { return float64(height)/2 - v/limit*(float64(height)/2-10) }
+
+	var svg strings.Builder
+	fmt.Fprintf(&svg, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" font-family="sans-serif" font-size="10">`, width, height)
+	fmt.Fprintf(&svg, `<rect width="%d" height="%d" fill="#2B2B2B"/>`, width, height)
+	fmt.Fprintf(&svg, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="#777777"/>`, pad, height/2, width-pad, height/2)
+	fmt.Fprintf(&svg, `<text x="2" y="14" fill="#64B5F6">+%.0f</text>`, limit)
+	fmt.Fprintf(&svg, `<text x="2" y="%d" fill="#E57373">-%.0f</text>`, height-4, limit)
+
+	for i := 0; i < len(values); i += 5 {
+		fmt.Fprintf(&svg, `<text x="%.1f" y="%d" fill="#FFFFFF" text-anchor="middle">%d</text>`, x(i), height/2+12, i)
+	}
+
+	points := make([]string, len(values))
+	for i, v := range values {
+		points[i] = fmt.Sprintf("%.1f,%.1f", x(i), y(v))
+	}
+	fmt.Fprintf(&svg, `<polyline points="%s" fill="none" stroke="#FFD54F" stroke-width="2"/>`, strings.Join(points, " "))
+
+	svg.WriteString(`</svg>`)
+
+	//Only numbers go into the SVG above, so it's safe to hand to the template as-is
+	return template.HTML(svg.String())
+}
+
+//timelineGraphs builds the team gold and XP graphs, then one pair of graphs for each lane matchup
+func timelineGraphs(ctx context.Context, s MatchStats, tl Timeline) []DiffGraph {
+
+	gold := func(f ParticipantFrame) int { return f.TotalGold }
+	xp := func(f ParticipantFrame) int { return f.XP }
+
+	var blue, red []int
+	for _, p := range s.Participants {
+		if p.TeamID == 100 {
+			blue = append(blue, p.ParticipantID)
+		} else {
+			red = append(red, p.ParticipantID)
+		}
+	}
+
+	graphs := []DiffGraph{
+		{Title: "Team gold difference", Chart: diffChart(frameDiff(tl, blue, red, gold))},
+		{Title: "Team XP difference", Chart: diffChart(frameDiff(tl, blue, red, xp))},
+	}
+
+	matchups := laneMatchups(s)
+	for _, pos := range positions {
+		pair, ok := matchups[pos]
+		if !ok {
+			continue
+		}
+
+		names := [2]string{}
+		for i, p := range pair {
+			name, err := championName(p.ChampionID)
+			if err != nil {
+				logFrom(ctx).Warn("champion name lookup failed", "err", err)
+				name = "Champion " + strconv.Itoa(p.ChampionID)
+			}
+			names[i] = name
+		}
+
+		title := pos + ": " + names[0] + " vs " + names[1]
+		b, r := []int{pair[0].ParticipantID}, []int{pair[1].ParticipantID}
+		graphs = append(graphs,
+			DiffGraph{Title: title + " (gold)", Chart: diffChart(frameDiff(tl, b, r, gold))},
+			DiffGraph{Title: title + " (XP)", Chart: diffChart(frameDiff(tl, b, r, xp))},
+		)
+	}
+
+	return graphs
+}
+
+//matchFunc serves /match?account=<id>&game=<gameID>, the gold and XP graphs for one archived match
+func matchFunc(response http.ResponseWriter, request *http.Request) {
+
+	accountID, err := strconv.Atoi(request.FormValue("account"))
+	if err != nil {
+		http.Error(response, "account must be a number", http.StatusBadRequest)
+		return
+	}
+	gameID, err := strconv.ParseInt(request.FormValue("game"), 10, 64)
+	if err != nil {
+		http.Error(response, "game must be a number", http.StatusBadRequest)
+		return
+	}
+
+	m, err := loadArchivedMatch(defaultRegion, accountID, gameID)
+	if err != nil {
+		http.Error(response, "That match isn't in the archive", http.StatusNotFound)
+		return
+	}
+
+	tl, ok, err := loadTimeline(defaultRegion, gameID)
+	if err != nil {
+		logFrom(request.Context()).Warn("timeline failed", "err", err)
+	}
+
+	page := struct {
+		Match       MatchInfo
+		HasTimeline bool
+		Graphs      []DiffGraph
+		Lane        *LaneDiff
+	}{Match: m, HasTimeline: ok}
+
+	var timeline *Timeline
+	if ok {
+		page.Graphs = timelineGraphs(request.Context(), m.Stats, tl)
+		timeline = &tl
+	}
+	if d, ok := laneDiff(request.Context(), m, timeline); ok {
+		page.Lane = &d
+	}
+
+	renderPage(request.Context(), response, "match", page)
+
+}
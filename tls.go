@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+//certCheckInterval is how often we look at the certificate files to see if they've been renewed
+const certCheckInterval = 30 * time.Second
+
+//certReloader hands out the certificate from certFile and keyFile, and swaps in a new one when the files change.
+//Renewing a certificate (say, with certbot) then never needs a restart.
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	mu       sync.Mutex
+	cert     *tls.Certificate
+	modified time.Time
+}
+
+//newCertReloader loads the certificate straight away, so a bad path fails at startup instead of on the first visitor
+func newCertReloader(certFile string, keyFile string) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile}
+	return r, r.reload()
+}
+
+//filesModified is the newest modification time of the two files, since they don't always get replaced at the same moment
+func (r *certReloader) filesModified() (time.Time, error) {
+
+	var newest time.Time
+	for _, name := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(name)
+		if err != nil {
+			return newest, err
+		}
+		if info.ModTime().After(newest) {
+			newest = info.ModTime()
+		}
+	}
+
+	return newest, nil
+}
+
+//reload reads both files again. If they don't make a valid pair, the old certificate stays.
+func (r *certReloader) reload() error {
+
+	modified, err := r.filesModified()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	r.cert = &cert
+	r.modified = modified
+	r.mu.Unlock()
+
+	return nil
+}
+
+//getCertificate is for tls.Config, which asks for the certificate on every handshake
+func (r *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cert, nil
+}
+
+//watch reloads the certificate whenever its files change, until ctx is done
+func (r *certReloader) watch(ctx context.Context) {
+
+	ticker := time.NewTicker(certCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+
+		modified, err := r.filesModified()
+		r.mu.Lock()
+		changed := err == nil && !modified.Equal(r.modified)
+		r.mu.Unlock()
+		if !changed {
+			continue
+		}
+
+		//A renewal halfway through being written fails here, and gets picked up on the next tick instead
+		if err := r.reload(); err != nil {
+			slog.Warn("reloading certificate failed, keeping the old one", "err", err)
+			continue
+		}
+		slog.Info("reloaded certificate", "cert", r.certFile)
+	}
+}
+
+//withHSTS tells browsers to only ever come back over HTTPS. It's only sent over HTTPS, since browsers ignore it otherwise.
+func withHSTS(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		if request.TLS != nil {
+			response.Header().Set("Strict-Transport-Security", "max-age=31536000")
+		}
+		next.ServeHTTP(response, request)
+	})
+}
+
+//redirectToHTTPS sends every plain HTTP request to the same page on httpsAddr.
+//The port is left off the new address when it's 443, so the usual setup gets clean links.
+func redirectToHTTPS(httpsAddr string) http.Handler {
+
+	_, port, _ := net.SplitHostPort(httpsAddr)
+
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+
+		host := request.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if port != "" && port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+
+		http.Redirect(response, request, "https://"+host+request.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+}
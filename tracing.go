@@ -0,0 +1,354 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//Span is one timed step of a request, like a single call to Riot or rendering a page.
+//Spans from the same request share a trace ID, and each one points at the span it happened inside of.
+type Span struct {
+	TraceID  [16]byte
+	SpanID   [8]byte
+	ParentID [8]byte
+	Name     string
+	Started  time.Time
+	Finished time.Time
+	Attrs    map[string]interface{}
+	Err      error
+
+	mu sync.Mutex
+}
+
+type spanKey struct{}
+
+//startSpan begins a span inside whatever span ctx already has, or a new trace if it has none.
+//attrs go in pairs of name and value. The span has to be finished with End.
+func startSpan(ctx context.Context, name string, attrs ...interface{}) (context.Context, *Span) {
+
+	s := &Span{Name: name, Started: time.Now(), Attrs: make(map[string]interface{})}
+	if parent, ok := ctx.Value(spanKey{}).(*Span); ok {
+		s.TraceID = parent.TraceID
+		s.ParentID = parent.SpanID
+	} else if remote, ok := ctx.Value(remoteParentKey{}).(remoteParent); ok {
+		s.TraceID = remote.TraceID
+		s.ParentID = remote.SpanID
+	} else {
+		rand.Read(s.TraceID[:])
+	}
+	rand.Read(s.SpanID[:])
+	s.Set(attrs...)
+
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+//Set adds attributes to the span, in pairs of name and value
+func (s *Span) Set(attrs ...interface{}) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := 0; i+1 < len(attrs); i += 2 {
+		if key, ok := attrs[i].(string); ok {
+			s.Attrs[key] = attrs[i+1]
+		}
+	}
+}
+
+//Fail marks the span as failed. A nil err leaves it alone, so it can be called with any error.
+func (s *Span) Fail(err error) {
+	if err == nil {
+		return
+	}
+	s.mu.Lock()
+	s.Err = err
+	s.mu.Unlock()
+}
+
+//End finishes the span and hands it to the exporter
+func (s *Span) End() {
+	s.mu.Lock()
+	s.Finished = time.Now()
+	s.mu.Unlock()
+	tracer.add(s)
+}
+
+//remoteParent is the trace a request was already part of when it reached us, from its traceparent header
+type remoteParent struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+}
+
+type remoteParentKey struct{}
+
+//parseTraceparent reads a W3C traceparent header, which looks like 00-<trace ID>-<parent span ID>-<flags>
+func parseTraceparent(header string) (remoteParent, bool) {
+
+	var p remoteParent
+	parts := strings.Split(header, "-")
+	if len(parts) != 4 || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return p, false
+	}
+	if _, err := hex.Decode(p.TraceID[:], []byte(parts[1])); err != nil {
+		return p, false
+	}
+	if _, err := hex.Decode(p.SpanID[:], []byte(parts[2])); err != nil {
+		return p, false
+	}
+
+	return p, p.TraceID != [16]byte{}
+}
+
+//withTracing puts every incoming request in a span. If whoever called us sent a traceparent, we join their trace.
+func withTracing(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+
+		ctx := request.Context()
+		if p, ok := parseTraceparent(request.Header.Get("traceparent")); ok {
+			ctx = context.WithValue(ctx, remoteParentKey{}, p)
+		}
+
+		ctx, span := startSpan(ctx, request.Method+" "+request.URL.Path, "http.method", request.Method, "http.route", request.URL.Path)
+		defer span.End()
+
+		recorder := &statusRecorder{ResponseWriter: response, status: http.StatusOK}
+		next.ServeHTTP(recorder, request.WithContext(ctx))
+
+		span.Set("http.status_code", recorder.status)
+		if recorder.status >= 500 {
+			span.Fail(fmt.Errorf("%d %s", recorder.status, http.StatusText(recorder.status)))
+		}
+	})
+}
+
+//render executes a page template inside its own span, so slow pages show up separately from slow API calls
+func render(ctx context.Context, t *template.Template, w io.Writer, data interface{}) {
+
+	_, span := startSpan(ctx, "render "+t.Name())
+	defer span.End()
+
+	if err := t.Execute(w, data); err != nil {
+		span.Fail(err)
+		logFrom(ctx).Warn("rendering page failed", "template", t.Name(), "err", err)
+	}
+}
+
+//spanExporter sends finished spans somewhere
+type spanExporter interface {
+	export(spans []*Span) error
+}
+
+//batchTracer collects finished spans and sends them off in batches, so tracing never holds up a request
+type batchTracer struct {
+	sync.Mutex
+	exporter spanExporter
+	pending  []*Span
+}
+
+//tracer is where every span goes. With no exporter set up, spans are simply dropped.
+var tracer = &batchTracer{}
+
+//maxBatch is how many spans we hold before sending them without waiting for the next flush
+const maxBatch = 256
+
+func (t *batchTracer) add(s *Span) {
+
+	t.Lock()
+	if t.exporter == nil {
+		t.Unlock()
+		return
+	}
+	t.pending = append(t.pending, s)
+	full := len(t.pending) >= maxBatch
+	t.Unlock()
+
+	if full {
+		go t.flush()
+	}
+}
+
+//flush sends everything collected so far
+func (t *batchTracer) flush() {
+
+	t.Lock()
+	spans, exporter := t.pending, t.exporter
+	t.pending = nil
+	t.Unlock()
+
+	if exporter == nil || len(spans) == 0 {
+		return
+	}
+	if err := exporter.export(spans); err != nil {
+		slog.Warn("exporting spans failed", "spans", len(spans), "err", err)
+	}
+}
+
+//flushLoop sends spans every interval until ctx is done, then sends whatever is left one last time
+func (t *batchTracer) flushLoop(ctx context.Context, interval time.Duration) {
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			t.flush()
+		case <-ctx.Done():
+			t.flush()
+			return
+		}
+	}
+}
+
+//setupTracing picks an exporter from IVERN_TRACE_EXPORTER: "otlp" sends to a collector at OTEL_EXPORTER_OTLP_ENDPOINT
+//(http://localhost:4318 if unset, the usual local collector), "stdout" prints each span as a line of JSON, and anything else turns tracing off.
+//Despite the name, "stdout" spans go to standard error alongside the logs, since commands like lookup and export write their results to standard output.
+//Commands flush once when they finish, and the website also flushes every few seconds from runServer.
+func setupTracing() {
+
+	switch os.Getenv("IVERN_TRACE_EXPORTER") {
+	case "otlp":
+		endpoint := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+		if endpoint == "" {
+			endpoint = "http://localhost:4318"
+		}
+		tracer.exporter = &otlpExporter{url: strings.TrimSuffix(endpoint, "/") + "/v1/traces", client: &http.Client{Timeout: 10 * time.Second}}
+	case "stdout":
+		tracer.exporter = &stdoutExporter{w: os.Stderr}
+	}
+}
+
+//stdoutExporter writes one JSON object per span, mostly for tests and trying things out
+type stdoutExporter struct {
+	sync.Mutex
+	w io.Writer
+}
+
+func (e *stdoutExporter) export(spans []*Span) error {
+
+	e.Lock()
+	defer e.Unlock()
+
+	enc := json.NewEncoder(e.w)
+	for _, s := range spans {
+		s.mu.Lock()
+		line := map[string]interface{}{
+			"traceId":    hex.EncodeToString(s.TraceID[:]),
+			"spanId":     hex.EncodeToString(s.SpanID[:]),
+			"name":       s.Name,
+			"start":      s.Started,
+			"durationMs": float64(s.Finished.Sub(s.Started).Microseconds()) / 1000,
+			"attributes": s.Attrs,
+		}
+		if s.ParentID != [8]byte{} {
+			line["parentSpanId"] = hex.EncodeToString(s.ParentID[:])
+		}
+		if s.Err != nil {
+			line["error"] = s.Err.Error()
+		}
+		s.mu.Unlock()
+
+		if err := enc.Encode(line); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+//otlpExporter sends spans to an OpenTelemetry collector using OTLP over HTTP, in its JSON encoding
+type otlpExporter struct {
+	url    string
+	client *http.Client
+}
+
+//otlpValue is OTLP's AnyValue. Only the kinds of values we actually put on spans are covered.
+func otlpValue(v interface{}) map[string]interface{} {
+	switch v := v.(type) {
+	case string:
+		return map[string]interface{}{"stringValue": v}
+	case bool:
+		return map[string]interface{}{"boolValue": v}
+	case int:
+		return map[string]interface{}{"intValue": strconv.Itoa(v)}
+	case int64:
+		return map[string]interface{}{"intValue": strconv.FormatInt(v, 10)}
+	case float64:
+		return map[string]interface{}{"doubleValue": v}
+	}
+	return map[string]interface{}{"stringValue": fmt.Sprint(v)}
+}
+
+func otlpAttributes(attrs map[string]interface{}) []map[string]interface{} {
+	list := []map[string]interface{}{}
+	for _, key := range sortedKeys(attrs) {
+		list = append(list, map[string]interface{}{"key": key, "value": otlpValue(attrs[key])})
+	}
+	return list
+}
+
+func (e *otlpExporter) export(spans []*Span) error {
+
+	var otlpSpans []map[string]interface{}
+	for _, s := range spans {
+		s.mu.Lock()
+		span := map[string]interface{}{
+			"traceId":           hex.EncodeToString(s.TraceID[:]),
+			"spanId":            hex.EncodeToString(s.SpanID[:]),
+			"name":              s.Name,
+			"kind":              1,
+			"startTimeUnixNano": strconv.FormatInt(s.Started.UnixNano(), 10),
+			"endTimeUnixNano":   strconv.FormatInt(s.Finished.UnixNano(), 10),
+			"attributes":        otlpAttributes(s.Attrs),
+			"status":            map[string]interface{}{"code": 1},
+		}
+		if s.ParentID != [8]byte{} {
+			span["parentSpanId"] = hex.EncodeToString(s.ParentID[:])
+		}
+		if s.Err != nil {
+			span["status"] = map[string]interface{}{"code": 2, "message": s.Err.Error()}
+		}
+		s.mu.Unlock()
+		otlpSpans = append(otlpSpans, span)
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"resourceSpans": []interface{}{map[string]interface{}{
+			"resource": map[string]interface{}{
+				"attributes": otlpAttributes(map[string]interface{}{"service.name": "ivern"}),
+			},
+			"scopeSpans": []interface{}{map[string]interface{}{
+				"scope": map[string]interface{}{"name": "ivern"},
+				"spans": otlpSpans,
+			}},
+		}},
+	})
+	if err != nil {
+		return err
+	}
+
+	resp, err := e.client.Post(e.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("otlp collector answered %s", resp.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestStdoutSpansStayOutOfCommandOutput(t *testing.T) {
+
+	old := tracer.exporter
+	t.Cleanup(func() { tracer.exporter = old })
+
+	//lookup and export write their results to standard output, so spans printed there would end up in the middle of them
+	t.Setenv("IVERN_TRACE_EXPORTER", "stdout")
+	setupTracing()
+
+	e, ok := tracer.exporter.(*stdoutExporter)
+	if !ok {
+		t.Fatalf("exporter is %T, want *stdoutExporter", tracer.exporter)
+	}
+	if e.w != os.Stderr {
+		t.Error("spans aren't written to standard error")
+	}
+}